@@ -2,17 +2,19 @@ package switchcraftgo
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // An instance of Brigadier.
 // Must be created with the [NewBrigadier] function.
 type Brigadier struct {
-	conn *Chatbox
-	cmds []*BrigadierCommand
-	name string
+	conn   *Chatbox
+	cmds   []*BrigadierCommand
+	name   string
+	logger *slog.Logger
 }
 
 // A registered Brigadier Command.
@@ -45,17 +47,35 @@ type BrigadierInvocation struct {
 	OwnerOnly    bool
 }
 
+// Options for [NewBrigadierWithOptions].
+type NewBrigadierOptions struct {
+	// Logger for command dispatch and argument errors. Defaults to the logger of the chatbox.
+	Logger *slog.Logger
+}
+
 // Creates a new instance of Brigadier.
 // Requires an instance of Chatbox passed, along with the name of the command.
+// Brigadier subscribes to command events with [Chatbox.On], so other handlers are kept.
+// Logs with the logger of the chatbox, see [NewBrigadierWithOptions] to use another logger.
 // Returns a reference to a [Brigadier] struct.
 func NewBrigadier(sc *Chatbox, name string) *Brigadier {
+	return NewBrigadierWithOptions(sc, name, NewBrigadierOptions{})
+}
+
+// Creates a new instance of Brigadier, like [NewBrigadier], with options such as the logger.
+func NewBrigadierWithOptions(sc *Chatbox, name string, opts NewBrigadierOptions) *Brigadier {
+	if opts.Logger == nil {
+		opts.Logger = sc.logger
+	}
+
 	b := &Brigadier{
-		conn: sc,
-		cmds: []*BrigadierCommand{},
-		name: name,
+		conn:   sc,
+		cmds:   []*BrigadierCommand{},
+		name:   name,
+		logger: opts.Logger.With("brigadier", name),
 	}
 
-	sc.OnCommand = func(packet ChatboxCommandPacket) {
+	Subscribe(sc, func(packet ChatboxCommandPacket) {
 		var cmd *BrigadierCommand
 
 		for _, value := range b.cmds {
@@ -68,15 +88,21 @@ func NewBrigadier(sc *Chatbox, name string) *Brigadier {
 			return
 		}
 
-		b.parse(cmd, packet)
-	}
+		b.parse(cmd, packet, cmd.name)
+	})
 
 	return b
 }
 
+// Internal function to reject a command the user ran, because it could not be parsed.
+func (b *Brigadier) rejectCommand(packet ChatboxCommandPacket, path, message string) {
+	b.logger.Info("Rejected command", "command", path, "user", packet.User.Name, "args", packet.Args, "reason", message)
+	b.tellError(packet.User.Uuid, message)
+}
+
 // Internal function to parse Command packets from Chatbox to Brigadier.
-// Will recurse itself with subcommands.
-func (b *Brigadier) parse(cmd *BrigadierCommand, packet ChatboxCommandPacket) {
+// Will recurse itself with subcommands. Path is the names of the commands so far, used for metrics.
+func (b *Brigadier) parse(cmd *BrigadierCommand, packet ChatboxCommandPacket, path string) {
 	var target *BrigadierCommand
 
 	if len(packet.Args) == 0 {
@@ -90,7 +116,7 @@ func (b *Brigadier) parse(cmd *BrigadierCommand, packet ChatboxCommandPacket) {
 					Command:   packet.Args[0],
 					Args:      packet.Args[1:],
 					OwnerOnly: packet.OwnerOnly,
-				})
+				}, path+" "+sub.name)
 
 				return
 			}
@@ -102,7 +128,7 @@ func (b *Brigadier) parse(cmd *BrigadierCommand, packet ChatboxCommandPacket) {
 	}
 
 	if target == nil || target.executes == nil {
-		b.tellError(packet.User.Uuid, fmt.Sprintf("No subcommand or argument found. Check out &7\\%s help &cfor more information.", cmd.name))
+		b.rejectCommand(packet, path, fmt.Sprintf("No subcommand or argument found. Check out &7\\%s help &cfor more information.", cmd.name))
 		return
 	}
 
@@ -112,7 +138,7 @@ func (b *Brigadier) parse(cmd *BrigadierCommand, packet ChatboxCommandPacket) {
 
 	for arg_name, arg := range target.arguments {
 		if len(packet.Args) <= int(arg.index) {
-			b.tellError(packet.User.Uuid, fmt.Sprintf("Missing argument \"%s\"", arg_name))
+			b.rejectCommand(packet, path, fmt.Sprintf("Missing argument \"%s\"", arg_name))
 			return
 		}
 		str := packet.Args[arg.index]
@@ -139,7 +165,7 @@ func (b *Brigadier) parse(cmd *BrigadierCommand, packet ChatboxCommandPacket) {
 			num, err := strconv.Atoi(str)
 
 			if err != nil {
-				b.tellError(packet.User.Uuid, fmt.Sprintf("Unable to convert \"%s\" to number", str))
+				b.rejectCommand(packet, path, fmt.Sprintf("Unable to convert \"%s\" to number", str))
 				return
 			}
 
@@ -148,7 +174,7 @@ func (b *Brigadier) parse(cmd *BrigadierCommand, packet ChatboxCommandPacket) {
 			boolean, err := strconv.ParseBool(strings.ToLower(str))
 
 			if err != nil {
-				b.tellError(packet.User.Uuid, fmt.Sprintf("Unable to convert \"%s\" to boolean", str))
+				b.rejectCommand(packet, path, fmt.Sprintf("Unable to convert \"%s\" to boolean", str))
 				return
 			}
 
@@ -156,6 +182,12 @@ func (b *Brigadier) parse(cmd *BrigadierCommand, packet ChatboxCommandPacket) {
 		}
 	}
 
+	b.logger.Debug("Dispatching command", "command", path, "user", packet.User.Name, "args", packet.Args)
+	start := time.Now()
+	defer func() {
+		b.conn.metrics.CommandHandled(path, time.Since(start))
+	}()
+
 	target.executes(&BrigadierInvocation{
 		parent:       cmd,
 		User:         &packet.User,
@@ -173,7 +205,8 @@ func (b *Brigadier) Register(commands ...*BrigadierCommand) {
 	for _, command := range commands {
 		err := command.verify()
 		if err != nil {
-			log.Panicf("error while parsing command %s: %s", command.name, err.Error())
+			b.logger.Error("Invalid command", "command", command.name, "error", err)
+			panic(fmt.Sprintf("error while parsing command %s: %s", command.name, err.Error()))
 		}
 
 		command.Then(b.Literal("help").Executes(func(bi *BrigadierInvocation) {
@@ -289,16 +322,49 @@ func (ev *BrigadierInvocation) ReplyMarkdown(message string) {
 	ev.brigadier.conn.Tell(ev.User.Uuid, message, ev.brigadier.name, ChatboxFormattingMarkdown)
 }
 
+// Replies to the user with the supplied message, in MiniMessage mode.
+// See [ChatboxMiniMessageBuilder] for building the message.
+func (ev *BrigadierInvocation) ReplyMiniMessage(message string) {
+	ev.brigadier.conn.Tell(ev.User.Uuid, message, ev.brigadier.name, ChatboxFormattingMiniMessage)
+}
+
+// Replies to the user in format mode, with the template formatted with [fmt.Sprintf].
+// Every argument is escaped with [EscapeFormat], so text supplied by users cannot add formatting,
+// while the formatting codes in the template are kept.
+func (ev *BrigadierInvocation) SafeReply(template string, args ...any) {
+	ev.Reply(sprintfEscaped(EscapeFormat, template, args...))
+}
+
+// Replies to the user in markdown mode, with the template formatted with [fmt.Sprintf].
+// Every argument is escaped with [EscapeMarkdown], so text supplied by users cannot add links or formatting,
+// while the markdown in the template is kept.
+func (ev *BrigadierInvocation) SafeReplyMarkdown(template string, args ...any) {
+	ev.ReplyMarkdown(sprintfEscaped(EscapeMarkdown, template, args...))
+}
+
+// Replies to the user in MiniMessage mode, with the template formatted with [fmt.Sprintf].
+// Every argument is escaped with [EscapeMiniMessage], so text supplied by users cannot add tags,
+// while the tags in the template are kept.
+func (ev *BrigadierInvocation) SafeReplyMiniMessage(template string, args ...any) {
+	ev.ReplyMiniMessage(sprintfEscaped(EscapeMiniMessage, template, args...))
+}
+
 // Replies to the uesr with an error.
 // The message is prepended with "Error: ".
 // Your message has to be in formatting mode, and is by default red.
 func (ev *BrigadierInvocation) Error(message string) {
-	ev.brigadier.tellError(ev.User.Uuid, fmt.Sprintf("&c&lError: &c%s", message))
+	ev.brigadier.tellError(ev.User.Uuid, message)
 }
 
 // Internal version of [Error], that also requires the user uuid to send to.
+// Errors skip ahead of other queued messages.
 func (b *Brigadier) tellError(user, message string) {
-	b.conn.Tell(user, fmt.Sprintf("&c&lError: &c%s", message), b.name, ChatboxFormattingFormat)
+	formatted := NewChatboxFormat().
+		Colour(ChatboxColourRed).Bold().Text("Error: ").
+		Colour(ChatboxColourRed).Text(message).
+		String()
+
+	b.conn.tell(user, formatted, b.name, ChatboxFormattingFormat, true)
 }
 
 // Internal function to validate that you are allowed to read the value you want.
@@ -1,17 +1,47 @@
 package switchcraftgo
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/url"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// How the text of a tell or say is formatted.
+type ChatboxFormattingMode int
+
 const (
-	ChatboxFormattingMarkdown = iota
+	// Discord style markdown, such as **bold** and [links](https://sc3.io).
+	ChatboxFormattingMarkdown ChatboxFormattingMode = iota
+	// Minecraft & formatting codes, such as &c for red. See [ChatboxFormatBuilder].
 	ChatboxFormattingFormat
+	// MiniMessage tags, such as <red> and <hover:show_text:'text'>. See [ChatboxMiniMessageBuilder].
+	ChatboxFormattingMiniMessage
 )
 
+// Returns the name of the mode, as used by the chatbox server.
+func (mode ChatboxFormattingMode) String() string {
+	switch mode {
+	case ChatboxFormattingMarkdown:
+		return "markdown"
+	case ChatboxFormattingFormat:
+		return "format"
+	case ChatboxFormattingMiniMessage:
+		return "minimessage"
+	}
+
+	return fmt.Sprintf("ChatboxFormattingMode(%d)", int(mode))
+}
+
 type ChatboxIngameUser struct {
 	Type        string              `json:"type"`
 	Name        string              `json:"name"`
@@ -50,6 +80,30 @@ type ChatboxTellPacket struct {
 	Text string `json:"text"`
 	Name string `json:"name"`
 	Mode string `json:"mode"`
+	Id   int    `json:"id"`
+}
+
+type ChatboxSayPacket struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+	Name string `json:"name"`
+	Mode string `json:"mode"`
+	Id   int    `json:"id"`
+}
+
+// Sent by the server when a packet with the same Id was successful.
+type ChatboxSuccessPacket struct {
+	Type   string `json:"type"`
+	Id     int    `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// Sent by the server when a packet with the same Id failed.
+type ChatboxErrorPacket struct {
+	Type    string `json:"type"`
+	Id      int    `json:"id"`
+	Error   string `json:"error"`
+	Message string `json:"message"`
 }
 
 type ChatboxCommandPacket struct {
@@ -58,22 +112,348 @@ type ChatboxCommandPacket struct {
 	Command   string            `json:"command"`
 	Args      []string          `json:"args"`
 	OwnerOnly bool              `json:"ownerOnly"`
+	Time      ChatboxTimestamp  `json:"time"`
+}
+
+// Sent when a player joins the server.
+type ChatboxJoinPacket struct {
+	Event string            `json:"event"`
+	User  ChatboxIngameUser `json:"user"`
+	Time  ChatboxTimestamp  `json:"time"`
+}
+
+// Sent when a player leaves the server.
+type ChatboxLeavePacket struct {
+	Event string            `json:"event"`
+	User  ChatboxIngameUser `json:"user"`
+	Time  ChatboxTimestamp  `json:"time"`
+}
+
+// Sent when a player dies.
+// RenderedText is the raw Minecraft text component of the death message.
+// Source is the player who killed the user, if they were killed by a player.
+type ChatboxDeathPacket struct {
+	Event        string             `json:"event"`
+	User         ChatboxIngameUser  `json:"user"`
+	Text         string             `json:"text"`
+	RawText      string             `json:"rawText"`
+	RenderedText json.RawMessage    `json:"renderedText"`
+	Source       *ChatboxIngameUser `json:"source"`
+	Time         ChatboxTimestamp   `json:"time"`
+
+	// The chatbox does not send these, so they are found from Text with [ClassifyDeathMessage].
+	// Killer is the name of the player or entity which killed the user, and Weapon the name of the item used.
+	// Both are empty if the message does not say.
+	Cause  ChatboxDeathCause `json:"-"`
+	Killer string            `json:"-"`
+	Weapon string            `json:"-"`
+}
+
+// Sent when a player moves between worlds, for example when entering the nether.
+type ChatboxWorldChangePacket struct {
+	Event       string            `json:"event"`
+	User        ChatboxIngameUser `json:"user"`
+	Origin      string            `json:"origin"`
+	Destination string            `json:"destination"`
+	Time        ChatboxTimestamp  `json:"time"`
+}
+
+// Sent when a player goes AFK.
+type ChatboxAfkPacket struct {
+	Event string            `json:"event"`
+	User  ChatboxIngameUser `json:"user"`
+	Time  ChatboxTimestamp  `json:"time"`
+}
+
+// Sent when a player returns from being AFK.
+type ChatboxAfkReturnPacket struct {
+	Event string            `json:"event"`
+	User  ChatboxIngameUser `json:"user"`
+	Time  ChatboxTimestamp  `json:"time"`
+}
+
+// Sent when a player sends a message in in-game chat.
+// Text has formatting stripped, RawText is the message as typed,
+// and RenderedText is the raw Minecraft text component of the message.
+type ChatboxIngameChatPacket struct {
+	Event        string            `json:"event"`
+	Text         string            `json:"text"`
+	RawText      string            `json:"rawText"`
+	RenderedText json.RawMessage   `json:"renderedText"`
+	User         ChatboxIngameUser `json:"user"`
+	Time         ChatboxTimestamp  `json:"time"`
+}
+
+// Sent when a user sends a message in the Discord server.
+// Edited messages are sent again with Edited set, and the same DiscordId.
+type ChatboxDiscordChatPacket struct {
+	Event        string               `json:"event"`
+	Text         string               `json:"text"`
+	RawText      string               `json:"rawText"`
+	RenderedText json.RawMessage      `json:"renderedText"`
+	DiscordId    string               `json:"discordId"`
+	DiscordUser  ChatboxDiscordUser   `json:"discordUser"`
+	Edited       bool                 `json:"edited"`
+	EditedTime   ChatboxTimestamp     `json:"editedTime"`
+	ReplyTo      *ChatboxDiscordReply `json:"replyTo"`
+	Time         ChatboxTimestamp     `json:"time"`
+}
+
+// The Discord message a [ChatboxDiscordChatPacket] is replying to.
+type ChatboxDiscordReply struct {
+	DiscordId   string              `json:"discordId"`
+	DiscordUser *ChatboxDiscordUser `json:"discordUser"`
+	Text        string              `json:"text"`
+}
+
+// Sent when another chatbox sends a public message with say.
+// User is the owner of the chatbox, and Name is the name the message was sent with.
+type ChatboxChatboxChatPacket struct {
+	Event        string            `json:"event"`
+	Text         string            `json:"text"`
+	RawText      string            `json:"rawText"`
+	RenderedText json.RawMessage   `json:"renderedText"`
+	User         ChatboxIngameUser `json:"user"`
+	Name         string            `json:"name"`
+	RawName      string            `json:"rawName"`
+	Time         ChatboxTimestamp  `json:"time"`
+}
+
+// A timestamp sent by the chatbox server.
+// Embeds [time.Time], and is decoded with the SwitchCraft timestamp format.
+type ChatboxTimestamp struct {
+	time.Time
+}
+
+func (t *ChatboxTimestamp) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if raw == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	parsed, err := parseScTimestamp(raw)
+	if err != nil {
+		return err
+	}
+
+	t.Time = parsed
+	return nil
 }
 
 type ChatboxGenericEventPacket struct {
+	Type  string `json:"type"`
 	Event string `json:"event"`
 }
 
+// Sent by the server right before it closes the connection.
+// CloseReason is a machine readable reason, such as "server_stopping", and Reason is human readable.
+type ChatboxClosingPacket struct {
+	Type        string `json:"type"`
+	CloseReason string `json:"closeReason"`
+	Reason      string `json:"reason"`
+}
+
+// Passed to [Chatbox.OnError] when a packet could not be decoded.
+// Type and Event are empty if the packet was not valid JSON.
+type ChatboxDecodeError struct {
+	Type   string
+	Event  string
+	Packet []byte
+	Err    error
+}
+
+func (e *ChatboxDecodeError) Error() string {
+	if e.Event != "" {
+		return fmt.Sprintf("failed to decode chatbox %s event: %s", e.Event, e.Err.Error())
+	}
+
+	return fmt.Sprintf("failed to decode chatbox %s packet: %s", e.Type, e.Err.Error())
+}
+
+func (e *ChatboxDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Returned when the server closed the connection, with the reasons from the closing packet.
+type ChatboxCloseError struct {
+	CloseReason string
+	Reason      string
+}
+
+func (e *ChatboxCloseError) Error() string {
+	return fmt.Sprintf("chatbox closed by server: %s: %s", e.CloseReason, e.Reason)
+}
+
+// An error sent by the chatbox server in response to a packet.
+// Compare it to the ErrChatbox errors with [errors.Is], which matches on Code.
+type ChatboxError struct {
+	Code    string
+	Message string
+}
+
+func (e *ChatboxError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("chatbox error: %s", e.Code)
+	}
+
+	return fmt.Sprintf("chatbox error: %s: %s", e.Code, e.Message)
+}
+
+func (e *ChatboxError) Is(target error) bool {
+	t, ok := target.(*ChatboxError)
+	return ok && t.Code == e.Code
+}
+
+var (
+	ErrChatboxUnknownError            = &ChatboxError{Code: "unknown_error"}
+	ErrChatboxRateLimited             = &ChatboxError{Code: "rate_limited"}
+	ErrChatboxInsufficientPermissions = &ChatboxError{Code: "insufficient_permissions"}
+	ErrChatboxUnknownType             = &ChatboxError{Code: "unknown_type"}
+	ErrChatboxMissingText             = &ChatboxError{Code: "missing_text"}
+	ErrChatboxMissingUser             = &ChatboxError{Code: "missing_user"}
+	ErrChatboxUnknownUser             = &ChatboxError{Code: "unknown_user"}
+)
+
+var (
+	ErrChatboxNotConnected = errors.New("chatbox is not connected")
+	ErrChatboxClosed       = errors.New("chatbox connection closed before a response was received")
+	// Returned by [Chatbox.Listen] when the server stopped answering keepalive pings.
+	ErrChatboxStale = errors.New("chatbox server did not answer keepalive ping")
+
+	// Reported in strict mode, wrapped in a [*ChatboxDecodeError].
+	ErrChatboxUnexpectedPacket = errors.New("unexpected packet type")
+	ErrChatboxUnexpectedEvent  = errors.New("unexpected event")
+)
+
+// The outcome of an outbound packet, such as [Chatbox.Tell] or [Chatbox.Say].
+// It is completed once the server has responded to the packet with the same Id.
+// For messages that were split into several packets, Id is the id of the first packet.
+type ChatboxResult struct {
+	Id     int
+	done   chan struct{}
+	reason string
+	err    error
+
+	onComplete func(err error)
+}
+
+// Returns a channel that is closed once the result is complete.
+func (r *ChatboxResult) Done() <-chan struct{} {
+	return r.done
+}
+
+// Blocks until the result is complete.
+// Returns the reason sent by the server on success, or the error.
+// Errors sent by the server are of type [*ChatboxError].
+func (r *ChatboxResult) Wait() (string, error) {
+	<-r.done
+	return r.reason, r.err
+}
+
+// Internal function to complete the result.
+// Must only be called once per result.
+func (r *ChatboxResult) complete(reason string, err error) {
+	r.reason = reason
+	r.err = err
+	close(r.done)
+
+	if r.onComplete != nil {
+		r.onComplete(err)
+	}
+}
+
+// A connection to the chatbox server.
+// Must be created with the [NewChatbox] function.
+//
+// Chatbox is safe for concurrent use. Messages sent with [Chatbox.Tell] and [Chatbox.Say]
+// are written by a single queue goroutine, and every other write to Conn is serialized,
+// so they can be called from any goroutine, such as Brigadier handlers.
+// Writing to Conn directly is not safe while the chatbox is in use.
 type Chatbox struct {
-	Conn      *websocket.Conn
-	scUrl     url.URL
-	OnRaw     func(int, []byte)
-	OnCommand func(ChatboxCommandPacket)
+	Conn                     *websocket.Conn
+	scUrl                    url.URL
+	token                    string
+	OnRaw                    func(int, []byte)
+	OnCommand                func(ChatboxCommandPacket)
+	OnJoin                   func(ChatboxJoinPacket)
+	OnLeave                  func(ChatboxLeavePacket)
+	OnDeath                  func(ChatboxDeathPacket)
+	OnWorldChange            func(ChatboxWorldChangePacket)
+	OnAfk                    func(ChatboxAfkPacket)
+	OnAfkReturn              func(ChatboxAfkReturnPacket)
+	OnChatIngame             func(ChatboxIngameChatPacket)
+	OnChatDiscord            func(ChatboxDiscordChatPacket)
+	OnChatChatbox            func(ChatboxChatboxChatPacket)
+	OnPlayers                func(ChatboxPlayersPacket)
+	OnHello                  func(ChatboxHello)
+	OnServerRestartScheduled func(ChatboxServerRestartScheduledPacket)
+	OnServerRestartCancelled func(ChatboxServerRestartCancelledPacket)
+
+	OnClosing      func(ChatboxClosingPacket)
+	OnDisconnect   func(error)
+	OnReconnecting func(attempt int, delay time.Duration)
+	OnReconnect    func()
+	OnError        func(error)
+
+	reconnect    ChatboxReconnectOptions
+	strict       bool
+	waitForHello bool
+	helloTimeout time.Duration
+	holdRestart  bool
+	connection   ChatboxConnectionOptions
+
+	queue     *chatboxQueue
+	queueOnce sync.Once
+
+	bus     chatboxBus
+	roster  *ChatboxRoster
+	metrics ChatboxMetrics
+	logger  *slog.Logger
+	latency atomic.Int64
+	writeMu sync.Mutex
+
+	mu           sync.Mutex
+	lastId       int
+	pending      map[int]*ChatboxResult
+	closing      *ChatboxClosingPacket
+	hello        *ChatboxHello
+	restart      *restartState
+	closed       bool
+	closeChan    chan struct{}
+	listening    chan struct{}
+	reconnecting chan struct{}
 }
 
+// Max time to wait for the server during the close handshake.
+const closeTimeout = time.Second
+
 type NewChatboxOptions struct {
-	Token string
-	Base  url.URL
+	Token      string
+	Base       url.URL
+	Reconnect  ChatboxReconnectOptions
+	Queue      ChatboxQueueOptions
+	Connection ChatboxConnectionOptions
+	// Report unknown packet types, events and fields to [Chatbox.OnError].
+	// Useful for noticing when the chatbox protocol has changed.
+	StrictDecoding bool
+	// Make [Chatbox.Connect] wait for the hello packet, so that [Chatbox.Hello] is known once it returns.
+	WaitForHello bool
+	// Max time to wait for the hello packet. Defaults to 10 seconds.
+	HelloTimeout time.Duration
+	// Hold queued messages from shortly before a scheduled server restart until it is over,
+	// or until two minutes after the restart time if it does not seem to happen.
+	// With reconnecting enabled, the chatbox also waits for the restart before reconnecting.
+	HoldDuringRestart bool
+	// Receives measurements of the chatbox and its Brigadiers, such as [ChatboxExpvarMetrics].
+	Metrics ChatboxMetrics
+	// Logs connections, packets and commands of the chatbox and its Brigadiers. Logs nothing if nil.
+	// Packets are logged at the debug level, and the token is never logged.
+	Logger *slog.Logger
 }
 
 func GetDefaultBase() url.URL {
@@ -84,6 +464,8 @@ func GetDefaultBase() url.URL {
 	}
 }
 
+// Creates a new chatbox, which must be connected with [Chatbox.Connect].
+// Without a token the "guest" token is used, which can only read chat, and cannot tell or say.
 func NewChatbox(opts NewChatboxOptions) *Chatbox {
 	scUrl := opts.Base
 	if scUrl.Host == "" {
@@ -94,61 +476,692 @@ func NewChatbox(opts NewChatboxOptions) *Chatbox {
 		opts.Token = "guest"
 	}
 
+	if opts.HelloTimeout <= 0 {
+		opts.HelloTimeout = 10 * time.Second
+	}
+
+	if opts.Metrics == nil {
+		opts.Metrics = noopMetrics{}
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = slog.New(discardHandler{})
+	}
+
 	scUrl.Path += opts.Token
 
 	sc := &Chatbox{
-		scUrl:     scUrl,
-		OnRaw:     func(_ int, _ []byte) {},
-		OnCommand: func(_ ChatboxCommandPacket) {},
+		scUrl:                    scUrl,
+		token:                    opts.Token,
+		OnRaw:                    func(_ int, _ []byte) {},
+		OnCommand:                func(_ ChatboxCommandPacket) {},
+		OnJoin:                   func(_ ChatboxJoinPacket) {},
+		OnLeave:                  func(_ ChatboxLeavePacket) {},
+		OnDeath:                  func(_ ChatboxDeathPacket) {},
+		OnWorldChange:            func(_ ChatboxWorldChangePacket) {},
+		OnAfk:                    func(_ ChatboxAfkPacket) {},
+		OnAfkReturn:              func(_ ChatboxAfkReturnPacket) {},
+		OnChatIngame:             func(_ ChatboxIngameChatPacket) {},
+		OnChatDiscord:            func(_ ChatboxDiscordChatPacket) {},
+		OnChatChatbox:            func(_ ChatboxChatboxChatPacket) {},
+		OnPlayers:                func(_ ChatboxPlayersPacket) {},
+		OnHello:                  func(_ ChatboxHello) {},
+		OnServerRestartScheduled: func(_ ChatboxServerRestartScheduledPacket) {},
+		OnServerRestartCancelled: func(_ ChatboxServerRestartCancelledPacket) {},
+		OnClosing:                func(_ ChatboxClosingPacket) {},
+		OnDisconnect:             func(_ error) {},
+		OnReconnecting:           func(_ int, _ time.Duration) {},
+		OnReconnect:              func() {},
+		OnError:                  func(_ error) {},
+		reconnect:                opts.Reconnect,
+		strict:                   opts.StrictDecoding,
+		waitForHello:             opts.WaitForHello,
+		helloTimeout:             opts.HelloTimeout,
+		holdRestart:              opts.HoldDuringRestart,
+		connection:               opts.Connection,
+		queue:                    newChatboxQueue(opts.Queue),
+		pending:                  map[int]*ChatboxResult{},
+		bus:                      chatboxBus{subscribers: map[string][]chatboxSubscriber{}},
+		roster:                   newChatboxRoster(),
+		metrics:                  opts.Metrics,
+		logger:                   opts.Logger,
+		closeChan:                make(chan struct{}),
 	}
 
 	return sc
 }
 
+// Connects to the chatbox server.
+// Equivalent to [Chatbox.ConnectContext] with [context.Background].
 func (sc *Chatbox) Connect() error {
-	conn, _, err := websocket.DefaultDialer.Dial(sc.scUrl.String(), nil)
+	return sc.ConnectContext(context.Background())
+}
+
+// Connects to the chatbox server.
+// The context only applies to the opening handshake, and can be used to set a timeout.
+// If [NewChatboxOptions.WaitForHello] is set, it also waits for the hello packet.
+func (sc *Chatbox) ConnectContext(ctx context.Context) error {
+	conn, _, err := sc.connection.dialer().DialContext(ctx, sc.scUrl.String(), sc.connection.Header)
 	if err != nil {
+		sc.logger.Warn("Failed to connect to chatbox", "url", sc.redactedUrl(), "error", err)
 		return err
 	}
+	sc.logger.Info("Connected to chatbox", "url", sc.redactedUrl())
+
+	if sc.connection.ReadLimit > 0 {
+		conn.SetReadLimit(sc.connection.ReadLimit)
+	}
+
+	sc.mu.Lock()
 	sc.Conn = conn
+	sc.closing = nil
+	sc.hello = nil
+	sc.mu.Unlock()
+
+	if sc.waitForHello {
+		if err := sc.awaitHello(ctx, conn); err != nil {
+			conn.Close()
+
+			sc.mu.Lock()
+			if sc.Conn == conn {
+				sc.Conn = nil
+			}
+			sc.mu.Unlock()
+			return err
+		}
+	}
 
 	return nil
 }
 
-func (sc *Chatbox) Listen() {
+// Reads packets from the connection and calls the handlers, until the connection is lost.
+// If reconnecting is enabled, the connection is reopened and listening continues,
+// unless the server closed the connection for a reason that will not go away by retrying,
+// or [Chatbox.Close] was called.
+//
+// Returns the error that stopped it, such as a [*ChatboxCloseError], or nil if it was closed.
+// Packets that fail to decode do not stop listening, and are reported to [Chatbox.OnError] instead.
+func (sc *Chatbox) Listen() error {
+	return sc.listen(context.Background())
+}
+
+// Connects if not already connected, and listens until the context is cancelled,
+// or the connection is lost for good.
+// On cancellation the chatbox is closed with [Chatbox.Close], and the context error is returned.
+// Otherwise it returns the error that stopped it, such as a [*ChatboxCloseError].
+func (sc *Chatbox) Run(ctx context.Context) error {
+	if sc.getConn() == nil {
+		if err := sc.ConnectContext(ctx); err != nil {
+			return err
+		}
+	}
+
+	closed := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		sc.Close()
+		close(closed)
+	})
+
+	err := sc.listen(ctx)
+	if !stop() {
+		<-closed
+		return ctx.Err()
+	}
+
+	return err
+}
+
+// Internal function to listen, and reconnect, until the connection is lost for good.
+// Returns nil if the chatbox was closed.
+func (sc *Chatbox) listen(ctx context.Context) error {
 	for {
-		messageType, message, err := sc.Conn.ReadMessage()
+		conn := sc.getConn()
+		err := sc.listenConn(conn)
+		sc.logger.Info("Disconnected from chatbox", "error", err)
+		if conn != nil {
+			// Reading has failed, but the socket may still be open, such as after a read timeout.
+			conn.Close()
+		}
+
+		sc.mu.Lock()
+		closed := sc.closed
+		closing := sc.closing
+		retry := !closed && sc.reconnect.Enabled && (closing == nil || !fatalCloseReasons[closing.CloseReason])
+		if retry {
+			// The queue waits for the new connection, instead of writing to the lost one.
+			sc.Conn = nil
+			sc.reconnecting = make(chan struct{})
+		}
+		sc.mu.Unlock()
+
+		sc.failPending(ErrChatboxClosed)
+		sc.OnDisconnect(err)
+
+		if closed {
+			return nil
+		}
+
+		if closing != nil {
+			err = &ChatboxCloseError{CloseReason: closing.CloseReason, Reason: closing.Reason}
+		}
+
+		if !retry {
+			return err
+		}
+
+		err = sc.reconnectLoop(ctx)
+
+		sc.mu.Lock()
+		close(sc.reconnecting)
+		sc.reconnecting = nil
+		sc.mu.Unlock()
+
 		if err != nil {
-			break
+			return err
 		}
 
-		sc.OnRaw(messageType, message)
+		if sc.isClosed() {
+			return nil
+		}
+	}
+}
+
+// Closes the connection with a close handshake, and stops [Chatbox.Listen] from reconnecting.
+// Event handlers are no longer called once closing has started.
+// If [ChatboxQueueOptions.DrainOnClose] is set, queued messages are sent and responded to first.
+// Messages still queued, or still waiting for a response, are failed with [ErrChatboxClosed].
+// A closed chatbox cannot be connected again, so create a new one with [NewChatbox] instead.
+func (sc *Chatbox) Close() error {
+	sc.mu.Lock()
+	if sc.closed {
+		sc.mu.Unlock()
+		return nil
+	}
+	sc.closed = true
+	sc.mu.Unlock()
+
+	sc.logger.Info("Closing chatbox", "queued", sc.QueueDepth())
+	if sc.queue.opts.DrainOnClose {
+		sc.drainQueue()
+	}
+
+	close(sc.closeChan)
+	sc.queue.failAll(ErrChatboxClosed)
+	sc.metrics.QueueDepth(0)
+
+	sc.mu.Lock()
+	conn := sc.Conn
+	listening := sc.listening
+	sc.mu.Unlock()
+
+	if conn == nil {
+		sc.failPending(ErrChatboxClosed)
+		return nil
+	}
+
+	// Ask the server to close, and wait for Listen to receive its reply.
+	message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	err := conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(closeTimeout))
+	if err == nil && listening != nil {
+		select {
+		case <-listening:
+		case <-time.After(closeTimeout):
+		}
+	}
+
+	err = conn.Close()
+	sc.failPending(ErrChatboxClosed)
+	return err
+}
+
+// Internal function to check whether [Chatbox.Close] has been called.
+func (sc *Chatbox) isClosed() bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	return sc.closed
+}
 
-		var parsed ChatboxGenericEventPacket
-		json.Unmarshal(message, &parsed)
+// Internal function to get the current connection.
+func (sc *Chatbox) getConn() *websocket.Conn {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
 
-		switch parsed.Event {
-		case "command":
-			var command ChatboxCommandPacket
-			json.Unmarshal(message, &command)
+	return sc.Conn
+}
+
+// Internal function to read packets from a single connection, until reading fails.
+func (sc *Chatbox) listenConn(conn *websocket.Conn) error {
+	if conn == nil {
+		return ErrChatboxNotConnected
+	}
+
+	listening := make(chan struct{})
+	sc.mu.Lock()
+	sc.listening = listening
+	sc.mu.Unlock()
+
+	defer func() {
+		sc.mu.Lock()
+		sc.listening = nil
+		sc.mu.Unlock()
+		close(listening)
+	}()
+
+	var stale atomic.Bool
+	if sc.connection.PingInterval > 0 {
+		stop := make(chan struct{})
+		defer close(stop)
+
+		pongs := make(chan string, 1)
+		conn.SetPongHandler(func(data string) error {
+			select {
+			case pongs <- data:
+			default:
+			}
+			return sc.extendReadDeadline(conn)
+		})
+		go sc.keepalive(conn, pongs, &stale, stop)
+	}
+
+	for {
+		if err := sc.extendReadDeadline(conn); err != nil {
+			return err
+		}
+
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			if stale.Load() {
+				return ErrChatboxStale
+			}
+			return err
+		}
+
+		sc.handleMessage(messageType, message)
+	}
+}
 
+// Internal function to decode a packet, and handle it.
+func (sc *Chatbox) handleMessage(messageType int, message []byte) {
+	sc.OnRaw(messageType, message)
+
+	var parsed ChatboxGenericEventPacket
+	if err := json.Unmarshal(message, &parsed); err != nil {
+		sc.reportDecodeError(&ChatboxDecodeError{Packet: message, Err: err})
+		return
+	}
+	sc.logger.Debug("Received packet", "type", parsed.Type, "event", parsed.Event)
+
+	if parsed.Type == "event" {
+		sc.metrics.PacketReceived(parsed.Event)
+	} else {
+		sc.metrics.PacketReceived(parsed.Type)
+	}
+
+	switch parsed.Type {
+	case "success":
+		var success ChatboxSuccessPacket
+		if sc.decode(parsed, message, &success) {
+			sc.resolve(success.Id, success.Reason, nil)
+		}
+	case "error":
+		var failure ChatboxErrorPacket
+		if sc.decode(parsed, message, &failure) {
+			sc.resolve(failure.Id, "", &ChatboxError{Code: failure.Error, Message: failure.Message})
+		}
+	case "closing":
+		var closing ChatboxClosingPacket
+		if sc.decode(parsed, message, &closing) {
+			sc.mu.Lock()
+			sc.closing = &closing
+			sc.mu.Unlock()
+
+			sc.logger.Info("Chatbox server is closing the connection", "close_reason", closing.CloseReason, "reason", closing.Reason)
+			sc.OnClosing(closing)
+		}
+	case "event":
+		if !sc.isClosed() {
+			sc.handleEvent(parsed, message)
+		}
+	case "hello":
+		var hello ChatboxHello
+		if sc.decode(parsed, message, &hello) {
+			sc.mu.Lock()
+			sc.hello = &hello
+			sc.mu.Unlock()
+			sc.logger.Info("Received hello", "guest", hello.Guest, "license_owner", hello.LicenseOwner, "capabilities", hello.Capabilities)
+
+			// A new connection after the restart time means that the restart is over.
+			sc.clearRestart(true)
+			sc.OnHello(hello)
+		}
+	case "players":
+		var players ChatboxPlayersPacket
+		if sc.decode(parsed, message, &players) {
+			sc.roster.reset(players.Players)
+			sc.OnPlayers(players)
+		}
+	default:
+		sc.reportUnexpected(parsed, message, ErrChatboxUnexpectedPacket)
+	}
+}
+
+// Internal function to decode an event packet, and call the matching handler.
+func (sc *Chatbox) handleEvent(parsed ChatboxGenericEventPacket, message []byte) {
+	switch parsed.Event {
+	case ChatboxEventCommand:
+		var command ChatboxCommandPacket
+		if sc.decode(parsed, message, &command) {
 			sc.OnCommand(command)
+			sc.emit(command)
+		}
+	case ChatboxEventJoin:
+		var join ChatboxJoinPacket
+		if sc.decode(parsed, message, &join) {
+			sc.roster.apply(join)
+			sc.OnJoin(join)
+			sc.emit(join)
 		}
+	case ChatboxEventLeave:
+		var leave ChatboxLeavePacket
+		if sc.decode(parsed, message, &leave) {
+			sc.roster.apply(leave)
+			sc.OnLeave(leave)
+			sc.emit(leave)
+		}
+	case ChatboxEventDeath:
+		var death ChatboxDeathPacket
+		if sc.decode(parsed, message, &death) {
+			death.classify()
+			sc.OnDeath(death)
+			sc.emit(death)
+		}
+	case ChatboxEventWorldChange:
+		var worldChange ChatboxWorldChangePacket
+		if sc.decode(parsed, message, &worldChange) {
+			sc.roster.apply(worldChange)
+			sc.OnWorldChange(worldChange)
+			sc.emit(worldChange)
+		}
+	case ChatboxEventAfk:
+		var afk ChatboxAfkPacket
+		if sc.decode(parsed, message, &afk) {
+			sc.roster.apply(afk)
+			sc.OnAfk(afk)
+			sc.emit(afk)
+		}
+	case ChatboxEventAfkReturn:
+		var afkReturn ChatboxAfkReturnPacket
+		if sc.decode(parsed, message, &afkReturn) {
+			sc.roster.apply(afkReturn)
+			sc.OnAfkReturn(afkReturn)
+			sc.emit(afkReturn)
+		}
+	case ChatboxEventChatIngame:
+		var chat ChatboxIngameChatPacket
+		if sc.decode(parsed, message, &chat) {
+			sc.OnChatIngame(chat)
+			sc.emit(chat)
+		}
+	case ChatboxEventChatDiscord:
+		var chat ChatboxDiscordChatPacket
+		if sc.decode(parsed, message, &chat) {
+			sc.OnChatDiscord(chat)
+			sc.emit(chat)
+		}
+	case ChatboxEventChatChatbox:
+		var chat ChatboxChatboxChatPacket
+		if sc.decode(parsed, message, &chat) {
+			sc.OnChatChatbox(chat)
+			sc.emit(chat)
+		}
+	case ChatboxEventServerRestartScheduled:
+		var restart ChatboxServerRestartScheduledPacket
+		if sc.decode(parsed, message, &restart) {
+			sc.scheduleRestart(restart)
+			sc.OnServerRestartScheduled(restart)
+			sc.emit(restart)
+		}
+	case ChatboxEventServerRestartCancelled:
+		var restart ChatboxServerRestartCancelledPacket
+		if sc.decode(parsed, message, &restart) {
+			sc.clearRestart(false)
+			sc.OnServerRestartCancelled(restart)
+			sc.emit(restart)
+		}
+	default:
+		sc.reportUnexpected(parsed, message, ErrChatboxUnexpectedEvent)
 	}
 }
 
-func (sc *Chatbox) Tell(user, message, name string, mode int) {
-	formattingMode := "markdown"
-	if mode == ChatboxFormattingFormat {
-		formattingMode = "format"
+// Internal function to decode a packet into v, reporting failures to [Chatbox.OnError].
+// Returns whether the packet was decoded, and should be handled.
+// In strict mode, unknown fields are also reported, but the packet is still handled.
+func (sc *Chatbox) decode(parsed ChatboxGenericEventPacket, message []byte, v any) bool {
+	if err := json.Unmarshal(message, v); err != nil {
+		sc.reportDecodeError(&ChatboxDecodeError{Type: parsed.Type, Event: parsed.Event, Packet: message, Err: err})
+		return false
 	}
-	packet := &ChatboxTellPacket{
-		Type: "tell",
-		User: user,
-		Text: message,
-		Name: name,
-		Mode: formattingMode,
+
+	if sc.strict {
+		if err := decodeStrict(message, reflect.New(reflect.TypeOf(v).Elem()).Interface()); err != nil {
+			sc.reportDecodeError(&ChatboxDecodeError{Type: parsed.Type, Event: parsed.Event, Packet: message, Err: err})
+		}
 	}
 
-	sc.Conn.WriteJSON(packet)
+	return true
+}
+
+// Internal function to report a packet type or event that this library does not know, in strict mode.
+func (sc *Chatbox) reportUnexpected(parsed ChatboxGenericEventPacket, message []byte, err error) {
+	if sc.strict {
+		sc.reportDecodeError(&ChatboxDecodeError{Type: parsed.Type, Event: parsed.Event, Packet: message, Err: err})
+	}
+}
+
+// Internal function to decode a packet, failing on fields that v does not have.
+// The "ok" field, and the "type" field of events, are sent with every packet, and are ignored.
+func decodeStrict(message []byte, v any) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(message, &fields); err != nil {
+		return err
+	}
+
+	delete(fields, "ok")
+	if _, ok := fields["event"]; ok {
+		delete(fields, "type")
+	}
+
+	stripped, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(stripped))
+	decoder.DisallowUnknownFields()
+
+	return decoder.Decode(v)
+}
+
+// Sends a private message to the user, only visible to them.
+// The user can be a name or UUID.
+// The message is queued, and sent once the rate limit allows it.
+// Messages longer than [ChatboxMaxTextLength] are split into several messages, see [Chatbox.Say].
+// Returns a [ChatboxResult] which completes once the server has responded,
+// or straight away with a [*ChatboxCapabilityError] if the license cannot tell.
+func (sc *Chatbox) Tell(user, message, name string, mode ChatboxFormattingMode) *ChatboxResult {
+	return sc.tell(user, message, name, mode, false)
+}
+
+// Internal version of [Chatbox.Tell], which can skip ahead of the queue.
+func (sc *Chatbox) tell(user, message, name string, mode ChatboxFormattingMode, priority bool) *ChatboxResult {
+	if err := sc.checkCapability(ChatboxCapabilityTell); err != nil {
+		return sc.failedResult("tell", err)
+	}
+
+	return sc.sendMessage("tell", user, message, mode, priority, func(text string, id int) any {
+		return &ChatboxTellPacket{
+			Type: "tell",
+			User: user,
+			Text: text,
+			Name: name,
+			Mode: mode.String(),
+			Id:   id,
+		}
+	})
+}
+
+// Sends a public message, visible to everyone in chat.
+// The message is queued, and sent once the rate limit allows it.
+//
+// Messages longer than [ChatboxMaxTextLength] are split into several messages, which are sent in order.
+// Splits are made at line breaks, or else between words, and never inside a formatting code, markdown span or MiniMessage tag.
+// In format and MiniMessage mode, the active colour and formatting is carried over to the next message.
+// Returns a [ChatboxResult] which completes once the server has responded,
+// or straight away with a [*ChatboxCapabilityError] if the license cannot say.
+func (sc *Chatbox) Say(message, name string, mode ChatboxFormattingMode) *ChatboxResult {
+	if err := sc.checkCapability(ChatboxCapabilitySay); err != nil {
+		return sc.failedResult("say", err)
+	}
+
+	return sc.sendMessage("say", "", message, mode, false, func(text string, id int) any {
+		return &ChatboxSayPacket{
+			Type: "say",
+			Text: text,
+			Name: name,
+			Mode: mode.String(),
+			Id:   id,
+		}
+	})
+}
+
+// Internal function to queue a message, split into parts no longer than [ChatboxMaxTextLength].
+// The parts are queued together, and are sent in order.
+// The result completes once every part has been responded to, with the first error of any part.
+// Kind is the packet type, and is used for metrics.
+func (sc *Chatbox) sendMessage(kind, recipient, message string, mode ChatboxFormattingMode, priority bool, packet func(text string, id int) any) *ChatboxResult {
+	parts := splitMessage(message, mode, ChatboxMaxTextLength)
+
+	items := make([]*queuedPacket, len(parts))
+	for idx, part := range parts {
+		result := sc.newResult()
+		result.onComplete = func(err error) {
+			sc.metrics.MessageSent(kind, err)
+		}
+		items[idx] = &queuedPacket{
+			recipient: recipient,
+			result:    result,
+			packet:    packet(part, result.Id),
+		}
+	}
+	sc.enqueue(items, priority)
+
+	if len(items) == 1 {
+		return items[0].result
+	}
+
+	combined := &ChatboxResult{
+		Id:   items[0].result.Id,
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		var reason string
+		var err error
+
+		for _, item := range items {
+			partReason, partErr := item.result.Wait()
+			if partErr != nil && err == nil {
+				err = partErr
+			}
+			reason = partReason
+		}
+
+		combined.complete(reason, err)
+	}()
+
+	return combined
+}
+
+// Internal function to create a result which has already failed with the error.
+// The failure is counted in the metrics as a message of the kind, such as "tell".
+func (sc *Chatbox) failedResult(kind string, err error) *ChatboxResult {
+	result := sc.newResult()
+	result.onComplete = func(err error) { sc.metrics.MessageSent(kind, err) }
+	result.complete("", err)
+
+	return result
+}
+
+// Internal function to create a result with the next packet id.
+func (sc *Chatbox) newResult() *ChatboxResult {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.lastId++
+	return &ChatboxResult{
+		Id:   sc.lastId,
+		done: make(chan struct{}),
+	}
+}
+
+// Internal function to write a packet, and await the response to it.
+// Completes the result if the write fails.
+func (sc *Chatbox) write(result *ChatboxResult, packet any) {
+	sc.mu.Lock()
+	conn := sc.Conn
+	sc.pending[result.Id] = result
+	sc.mu.Unlock()
+
+	if conn == nil {
+		sc.resolve(result.Id, "", ErrChatboxNotConnected)
+		return
+	}
+
+	if err := sc.writeJSON(conn, packet); err != nil {
+		sc.logger.Warn("Failed to send packet", "id", result.Id, "error", err)
+		sc.resolve(result.Id, "", err)
+	}
+}
+
+// Internal function to write a packet to the connection.
+// Every data frame must be written with this, as the connection only supports one writer at a time.
+func (sc *Chatbox) writeJSON(conn *websocket.Conn, packet any) error {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+
+	if sc.connection.WriteTimeout > 0 {
+		if err := conn.SetWriteDeadline(time.Now().Add(sc.connection.WriteTimeout)); err != nil {
+			return err
+		}
+	}
+
+	return conn.WriteJSON(packet)
+}
+
+// Internal function to complete the pending result with the id.
+// Does nothing if no result is pending with that id.
+func (sc *Chatbox) resolve(id int, reason string, err error) {
+	sc.mu.Lock()
+	result, ok := sc.pending[id]
+	delete(sc.pending, id)
+	sc.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	result.complete(reason, err)
+}
+
+// Internal function to fail every pending result with the error.
+func (sc *Chatbox) failPending(err error) {
+	sc.mu.Lock()
+	pending := sc.pending
+	sc.pending = map[int]*ChatboxResult{}
+	sc.mu.Unlock()
+
+	for _, result := range pending {
+		result.complete("", err)
+	}
 }
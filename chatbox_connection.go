@@ -0,0 +1,48 @@
+package switchcraftgo
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Options for the websocket connection of a [Chatbox].
+type ChatboxConnectionOptions struct {
+	// Dialer used to connect, which sets the proxy, TLS config, handshake timeout and compression.
+	// Defaults to [websocket.DefaultDialer].
+	Dialer *websocket.Dialer
+	// Extra headers sent with the opening handshake, such as a User-Agent.
+	Header http.Header
+	// Max size of a received message in bytes. Larger messages close the connection. Zero means no limit.
+	ReadLimit int64
+	// Max time between received messages, before the connection is considered lost.
+	// The chatbox server can go a long time without sending anything, so this is best used with keepalive pings.
+	// Zero means no limit.
+	ReadTimeout time.Duration
+	// Max time for writing a single message. Zero means no limit.
+	WriteTimeout time.Duration
+	// Interval between keepalive pings. A connection which does not answer a ping in time is treated as lost,
+	// so [Chatbox.Listen] returns [ErrChatboxStale], or reconnects if enabled. Zero disables pings.
+	PingInterval time.Duration
+	// Max time to wait for the answer to a ping. Defaults to PingInterval.
+	PongTimeout time.Duration
+}
+
+// Internal function to get the dialer to connect with.
+func (opts ChatboxConnectionOptions) dialer() *websocket.Dialer {
+	if opts.Dialer == nil {
+		return websocket.DefaultDialer
+	}
+
+	return opts.Dialer
+}
+
+// Internal function to move the read deadline of the connection forward, if there is a read timeout.
+func (sc *Chatbox) extendReadDeadline(conn *websocket.Conn) error {
+	if sc.connection.ReadTimeout <= 0 {
+		return nil
+	}
+
+	return conn.SetReadDeadline(time.Now().Add(sc.connection.ReadTimeout))
+}
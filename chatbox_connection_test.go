@@ -0,0 +1,100 @@
+package switchcraftgo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestChatboxConnectionOptions(t *testing.T) {
+	userAgent := make(chan string, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent <- r.Header.Get("User-Agent")
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"join","user":{"name":"`+strings.Repeat("a", 200)+`"}}`))
+		conn.ReadMessage()
+	}))
+	t.Cleanup(server.Close)
+
+	var dials atomic.Int32
+	sc := NewChatbox(NewChatboxOptions{
+		Token: "test",
+		Base:  url.URL{Scheme: "ws", Host: strings.TrimPrefix(server.URL, "http://"), Path: "/v2/"},
+		Connection: ChatboxConnectionOptions{
+			Dialer: &websocket.Dialer{
+				NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					dials.Add(1)
+					return (&net.Dialer{}).DialContext(ctx, network, addr)
+				},
+			},
+			Header:    http.Header{"User-Agent": []string{"switchcraftgo-test"}},
+			ReadLimit: 100,
+		},
+	})
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	if dials.Load() != 1 {
+		t.Fatalf("expected the custom dialer to be used")
+	}
+	if agent := <-userAgent; agent != "switchcraftgo-test" {
+		t.Fatalf("expected the extra header to be sent, got User-Agent %q", agent)
+	}
+
+	if err := sc.Listen(); !errors.Is(err, websocket.ErrReadLimit) {
+		t.Fatalf("expected Listen() to stop with the read limit error, got %v", err)
+	}
+}
+
+func TestChatboxReadTimeout(t *testing.T) {
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		conn.ReadMessage()
+	})
+	sc.connection.ReadTimeout = 50 * time.Millisecond
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+
+	var netErr net.Error
+	if err := sc.Listen(); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected Listen() to stop with a timeout, got %v", err)
+	}
+}
+
+func TestChatboxReadTimeoutCloses(t *testing.T) {
+	released := make(chan struct{})
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		conn.ReadMessage()
+		close(released)
+	})
+	sc.connection.ReadTimeout = 30 * time.Millisecond
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	sc.Listen()
+
+	select {
+	case <-released:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("connection was left open after reading timed out")
+	}
+}
@@ -0,0 +1,237 @@
+package switchcraftgo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// What killed a player, as found by [ClassifyDeathMessage].
+type ChatboxDeathCause int
+
+const (
+	// The death message did not match any known message.
+	ChatboxDeathUnknown ChatboxDeathCause = iota
+	// The player died without a more specific cause, such as from /kill.
+	ChatboxDeathGeneric
+	// Killed in melee by a player or mob.
+	ChatboxDeathMelee
+	// Shot with an arrow, trident, fireball or other projectile.
+	ChatboxDeathProjectile
+	ChatboxDeathExplosion
+	// Fell from a high place, or flew into a wall with an elytra.
+	ChatboxDeathFall
+	// Crushed by a falling anvil, block or stalactite.
+	ChatboxDeathFallingBlock
+	ChatboxDeathFire
+	ChatboxDeathLava
+	ChatboxDeathDrowning
+	// Suffocated in a wall, or squished by too many entities.
+	ChatboxDeathSuffocation
+	ChatboxDeathStarvation
+	// Fell out of the world, or left the world border.
+	ChatboxDeathVoid
+	// Killed by potions, dragon's breath or other magic.
+	ChatboxDeathMagic
+	ChatboxDeathWither
+	ChatboxDeathLightning
+	ChatboxDeathFreezing
+	// Pricked by a cactus, sweet berry bush or stalagmite.
+	ChatboxDeathContact
+	// Killed by thorns while attacking.
+	ChatboxDeathThorns
+	// Killed by a warden's sonic boom.
+	ChatboxDeathSonicBoom
+)
+
+var chatboxDeathCauseNames = []string{
+	"unknown", "generic", "melee", "projectile", "explosion", "fall", "falling_block", "fire", "lava", "drowning",
+	"suffocation", "starvation", "void", "magic", "wither", "lightning", "freezing", "contact", "thorns", "sonic_boom",
+}
+
+// Returns the name of the cause, such as "falling_block".
+func (cause ChatboxDeathCause) String() string {
+	if cause < 0 || int(cause) >= len(chatboxDeathCauseNames) {
+		return fmt.Sprintf("ChatboxDeathCause(%d)", int(cause))
+	}
+
+	return chatboxDeathCauseNames[cause]
+}
+
+// The details of a death, found from its message by [ClassifyDeathMessage].
+// Victim is the name of the player who died. Killer and Weapon are empty if the message does not say.
+type ChatboxDeathDetails struct {
+	Cause  ChatboxDeathCause
+	Victim string
+	Killer string
+	Weapon string
+}
+
+// The vanilla death messages, with the cause they are for. %1$s is the victim, %2$s the killer and %3$s the weapon.
+// Messages which start with another message must come first, so they are matched instead of it.
+var deathMessageTemplates = []struct {
+	cause    ChatboxDeathCause
+	template string
+}{
+	{ChatboxDeathMelee, "%1$s was slain by %2$s using %3$s"},
+	{ChatboxDeathMelee, "%1$s was slain by %2$s"},
+	{ChatboxDeathMelee, "%1$s was stung to death by %2$s using %3$s"},
+	{ChatboxDeathMelee, "%1$s was stung to death by %2$s"},
+	{ChatboxDeathMelee, "%1$s was stung to death"},
+	{ChatboxDeathProjectile, "%1$s was shot by %2$s using %3$s"},
+	{ChatboxDeathProjectile, "%1$s was shot by %2$s"},
+	{ChatboxDeathProjectile, "%1$s was fireballed by %2$s using %3$s"},
+	{ChatboxDeathProjectile, "%1$s was fireballed by %2$s"},
+	{ChatboxDeathProjectile, "%1$s was pummeled by %2$s using %3$s"},
+	{ChatboxDeathProjectile, "%1$s was pummeled by %2$s"},
+	{ChatboxDeathProjectile, "%1$s was impaled by %2$s using %3$s"},
+	{ChatboxDeathProjectile, "%1$s was impaled by %2$s"},
+	{ChatboxDeathExplosion, "%1$s was blown up by %2$s using %3$s"},
+	{ChatboxDeathExplosion, "%1$s was blown up by %2$s"},
+	{ChatboxDeathExplosion, "%1$s blew up"},
+	{ChatboxDeathExplosion, "%1$s was killed by [Intentional Game Design]"},
+	{ChatboxDeathExplosion, "%1$s went off with a bang due to a firework fired from %3$s by %2$s"},
+	{ChatboxDeathExplosion, "%1$s went off with a bang"},
+	{ChatboxDeathFall, "%1$s hit the ground too hard while trying to escape %2$s"},
+	{ChatboxDeathFall, "%1$s hit the ground too hard"},
+	{ChatboxDeathFall, "%1$s fell from a high place"},
+	{ChatboxDeathFall, "%1$s fell off a ladder"},
+	{ChatboxDeathFall, "%1$s fell off some vines"},
+	{ChatboxDeathFall, "%1$s fell off some weeping vines"},
+	{ChatboxDeathFall, "%1$s fell off some twisting vines"},
+	{ChatboxDeathFall, "%1$s fell off scaffolding"},
+	{ChatboxDeathFall, "%1$s fell while climbing"},
+	{ChatboxDeathFall, "%1$s was doomed to fall by %2$s using %3$s"},
+	{ChatboxDeathFall, "%1$s was doomed to fall by %2$s"},
+	{ChatboxDeathFall, "%1$s was doomed to fall"},
+	{ChatboxDeathFall, "%1$s fell too far and was finished by %2$s using %3$s"},
+	{ChatboxDeathFall, "%1$s fell too far and was finished by %2$s"},
+	{ChatboxDeathFall, "%1$s experienced kinetic energy while trying to escape %2$s"},
+	{ChatboxDeathFall, "%1$s experienced kinetic energy"},
+	{ChatboxDeathFallingBlock, "%1$s was squashed by a falling anvil while fighting %2$s"},
+	{ChatboxDeathFallingBlock, "%1$s was squashed by a falling anvil"},
+	{ChatboxDeathFallingBlock, "%1$s was squashed by a falling block while fighting %2$s"},
+	{ChatboxDeathFallingBlock, "%1$s was squashed by a falling block"},
+	{ChatboxDeathFallingBlock, "%1$s was skewered by a falling stalactite while fighting %2$s"},
+	{ChatboxDeathFallingBlock, "%1$s was skewered by a falling stalactite"},
+	{ChatboxDeathFire, "%1$s went up in flames"},
+	{ChatboxDeathFire, "%1$s walked into fire while fighting %2$s"},
+	{ChatboxDeathFire, "%1$s burned to death"},
+	{ChatboxDeathFire, "%1$s was burned to a crisp while fighting %2$s"},
+	{ChatboxDeathLava, "%1$s tried to swim in lava to escape %2$s"},
+	{ChatboxDeathLava, "%1$s tried to swim in lava"},
+	{ChatboxDeathLava, "%1$s discovered the floor was lava"},
+	{ChatboxDeathLava, "%1$s walked into the danger zone due to %2$s"},
+	{ChatboxDeathDrowning, "%1$s drowned while trying to escape %2$s"},
+	{ChatboxDeathDrowning, "%1$s drowned"},
+	{ChatboxDeathSuffocation, "%1$s suffocated in a wall while fighting %2$s"},
+	{ChatboxDeathSuffocation, "%1$s suffocated in a wall"},
+	{ChatboxDeathSuffocation, "%1$s was squished too much"},
+	{ChatboxDeathSuffocation, "%1$s was squashed by %2$s"},
+	{ChatboxDeathStarvation, "%1$s starved to death while fighting %2$s"},
+	{ChatboxDeathStarvation, "%1$s starved to death"},
+	{ChatboxDeathVoid, "%1$s fell out of the world"},
+	{ChatboxDeathVoid, "%1$s didn't want to live in the same world as %2$s"},
+	{ChatboxDeathVoid, "%1$s left the confines of this world while fighting %2$s"},
+	{ChatboxDeathVoid, "%1$s left the confines of this world"},
+	{ChatboxDeathMagic, "%1$s was killed by magic while trying to escape %2$s"},
+	{ChatboxDeathMagic, "%1$s was killed by magic"},
+	{ChatboxDeathMagic, "%1$s was killed by %2$s using magic"},
+	{ChatboxDeathMagic, "%1$s was roasted in dragon's breath by %2$s"},
+	{ChatboxDeathMagic, "%1$s was roasted in dragon's breath"},
+	{ChatboxDeathWither, "%1$s withered away while fighting %2$s"},
+	{ChatboxDeathWither, "%1$s withered away"},
+	{ChatboxDeathWither, "%1$s was withered by %2$s"},
+	{ChatboxDeathLightning, "%1$s was struck by lightning while fighting %2$s"},
+	{ChatboxDeathLightning, "%1$s was struck by lightning"},
+	{ChatboxDeathFreezing, "%1$s froze to death"},
+	{ChatboxDeathFreezing, "%1$s was frozen to death by %2$s"},
+	{ChatboxDeathContact, "%1$s was pricked to death"},
+	{ChatboxDeathContact, "%1$s walked into a cactus while trying to escape %2$s"},
+	{ChatboxDeathContact, "%1$s was poked to death by a sweet berry bush while trying to escape %2$s"},
+	{ChatboxDeathContact, "%1$s was poked to death by a sweet berry bush"},
+	{ChatboxDeathContact, "%1$s was impaled on a stalagmite while fighting %2$s"},
+	{ChatboxDeathContact, "%1$s was impaled on a stalagmite"},
+	{ChatboxDeathThorns, "%1$s was killed while trying to hurt %2$s"},
+	{ChatboxDeathSonicBoom, "%1$s was obliterated by a sonically-charged shriek while trying to escape %2$s"},
+	{ChatboxDeathSonicBoom, "%1$s was obliterated by a sonically-charged shriek"},
+	{ChatboxDeathGeneric, "%1$s was killed by %2$s using %3$s"},
+	{ChatboxDeathGeneric, "%1$s was killed by %2$s"},
+	{ChatboxDeathGeneric, "%1$s was killed"},
+	{ChatboxDeathGeneric, "%1$s died because of %2$s"},
+	{ChatboxDeathGeneric, "%1$s died"},
+}
+
+type deathMessagePattern struct {
+	cause   ChatboxDeathCause
+	pattern *regexp.Regexp
+}
+
+// The templates, compiled to regular expressions with the named groups victim, killer and weapon.
+var deathMessagePatterns = compileDeathMessageTemplates()
+
+func compileDeathMessageTemplates() []deathMessagePattern {
+	groups := strings.NewReplacer(
+		regexp.QuoteMeta("%1$s"), "(?P<victim>.+?)",
+		regexp.QuoteMeta("%2$s"), "(?P<killer>.+?)",
+		regexp.QuoteMeta("%3$s"), "(?P<weapon>.+?)",
+	)
+
+	patterns := make([]deathMessagePattern, len(deathMessageTemplates))
+	for idx, template := range deathMessageTemplates {
+		patterns[idx] = deathMessagePattern{
+			cause:   template.cause,
+			pattern: regexp.MustCompile("^" + groups.Replace(regexp.QuoteMeta(template.template)) + "$"),
+		}
+	}
+
+	return patterns
+}
+
+// Finds the cause, victim, killer and weapon of a death from its message, using the vanilla death messages.
+// Item names shown in brackets, such as [Diamond Sword], are returned without the brackets.
+// Returns [ChatboxDeathUnknown] as the cause if the message is not a known death message,
+// such as one from a mod or plugin.
+func ClassifyDeathMessage(message string) ChatboxDeathDetails {
+	message = strings.TrimSpace(message)
+
+	for _, pattern := range deathMessagePatterns {
+		match := pattern.pattern.FindStringSubmatch(message)
+		if match == nil {
+			continue
+		}
+
+		details := ChatboxDeathDetails{Cause: pattern.cause}
+		for idx, name := range pattern.pattern.SubexpNames() {
+			switch name {
+			case "victim":
+				details.Victim = match[idx]
+			case "killer":
+				details.Killer = match[idx]
+			case "weapon":
+				details.Weapon = strings.TrimSuffix(strings.TrimPrefix(match[idx], "["), "]")
+			}
+		}
+
+		return details
+	}
+
+	return ChatboxDeathDetails{Cause: ChatboxDeathUnknown}
+}
+
+// Internal function to fill in the cause, killer and weapon of the death from its message.
+func (packet *ChatboxDeathPacket) classify() {
+	text := packet.Text
+	if text == "" {
+		text = packet.RawText
+	}
+
+	details := ClassifyDeathMessage(text)
+	packet.Cause = details.Cause
+	packet.Killer = details.Killer
+	packet.Weapon = details.Weapon
+
+	if packet.Killer == "" && packet.Source != nil {
+		packet.Killer = packet.Source.Name
+	}
+}
@@ -0,0 +1,51 @@
+package switchcraftgo
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestClassifyDeathMessage(t *testing.T) {
+	cases := map[string]ChatboxDeathDetails{
+		"Erb3 was slain by Zombie":                           {ChatboxDeathMelee, "Erb3", "Zombie", ""},
+		"Erb3 was slain by Lemmmy using [Diamond Sword]":     {ChatboxDeathMelee, "Erb3", "Lemmmy", "Diamond Sword"},
+		"Erb3 was shot by Skeleton":                          {ChatboxDeathProjectile, "Erb3", "Skeleton", ""},
+		"Erb3 fell from a high place":                        {ChatboxDeathFall, "Erb3", "", ""},
+		"Erb3 was doomed to fall by Lemmmy using [Bow]":      {ChatboxDeathFall, "Erb3", "Lemmmy", "Bow"},
+		"Erb3 tried to swim in lava to escape Blaze":         {ChatboxDeathLava, "Erb3", "Blaze", ""},
+		"Erb3 was killed by Witch using magic":               {ChatboxDeathMagic, "Erb3", "Witch", ""},
+		"Erb3 was killed by [Intentional Game Design]":       {ChatboxDeathExplosion, "Erb3", "", ""},
+		"Erb3 was obliterated by a sonically-charged shriek": {ChatboxDeathSonicBoom, "Erb3", "", ""},
+		"Erb3 drowned":             {ChatboxDeathDrowning, "Erb3", "", ""},
+		"Erb3 was eaten by a grue": {ChatboxDeathUnknown, "", "", ""},
+		"Erb3 was squashed by a falling anvil while fighting Bo": {ChatboxDeathFallingBlock, "Erb3", "Bo", ""},
+	}
+
+	for message, expected := range cases {
+		if details := ClassifyDeathMessage(message); details != expected {
+			t.Errorf("ClassifyDeathMessage(%q): expected %+v, got %+v", message, expected, details)
+		}
+	}
+}
+
+func TestChatboxDeathEvent(t *testing.T) {
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"death","user":{"type":"ingame","name":"Erb3","uuid":"1234"},"text":"Erb3 was slain by Lemmmy using [Sword]","source":{"type":"ingame","name":"Lemmmy","uuid":"5678"},"time":"2024-05-29T15:16:28Z"}`))
+	})
+
+	var death ChatboxDeathPacket
+	sc.OnDeath = func(p ChatboxDeathPacket) { death = p }
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	sc.Listen()
+
+	if death.Cause != ChatboxDeathMelee || death.Killer != "Lemmmy" || death.Weapon != "Sword" || death.Source.Uuid != "5678" {
+		t.Fatalf("death event was not classified, got %+v", death)
+	}
+	if death.Cause.String() != "melee" {
+		t.Fatalf("expected cause name melee, got %q", death.Cause.String())
+	}
+}
@@ -0,0 +1,110 @@
+package switchcraftgo
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Reports whether the user has the role with the ID.
+func (u *ChatboxDiscordUser) HasRole(id uint64) bool {
+	for _, role := range u.Roles {
+		if role.Id == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reports whether the user has a role with the name, ignoring case.
+func (u *ChatboxDiscordUser) HasRoleNamed(name string) bool {
+	for _, role := range u.Roles {
+		if strings.EqualFold(role.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the highest role of the user, or nil if they have no roles.
+// The chatbox sends the roles ordered from highest to lowest.
+func (u *ChatboxDiscordUser) HighestRole() *ChatboxDiscordRole {
+	if len(u.Roles) == 0 {
+		return nil
+	}
+
+	return u.Roles[0]
+}
+
+// Reports whether the role has a colour. Roles without a colour have a colour of 0.
+func (r *ChatboxDiscordRole) HasColour() bool {
+	return r.Colour != 0
+}
+
+// Returns the colour of the role as hex, such as "#ff5555", or an empty string if it has no colour.
+func (r *ChatboxDiscordRole) HexColour() string {
+	if !r.HasColour() {
+		return ""
+	}
+
+	return hexColour(r.Colour)
+}
+
+// Returns the Minecraft colour closest to the colour of the role, for [ChatboxFormattingFormat] mode.
+// Roles without a colour are white.
+func (r *ChatboxDiscordRole) FormatColour() ChatboxColour {
+	if !r.HasColour() {
+		return ChatboxColourWhite
+	}
+
+	return NearestChatboxColour(r.Colour)
+}
+
+// A person, who may have spoken from in-game or from Discord, with the accounts linked to them.
+// Either user may be nil, if the person has not linked their accounts.
+type ChatboxIdentity struct {
+	Ingame  *ChatboxIngameUser
+	Discord *ChatboxDiscordUser
+}
+
+// Returns the identity of the in-game user, following the link to their Discord account.
+func (u *ChatboxIngameUser) Identity() ChatboxIdentity {
+	return ChatboxIdentity{Ingame: u, Discord: u.LinkedUser}
+}
+
+// Returns the identity of the Discord user, following the link to their in-game account.
+func (u *ChatboxDiscordUser) Identity() ChatboxIdentity {
+	return ChatboxIdentity{Ingame: u.LinkedUser, Discord: u}
+}
+
+// Returns a key which is the same for the person, whichever side they spoke from.
+// The key is "minecraft:" and the UUID if they have an in-game account,
+// or else "discord:" and the Discord ID.
+func (i ChatboxIdentity) Key() string {
+	if i.Ingame != nil {
+		return "minecraft:" + i.Ingame.Uuid
+	}
+	if i.Discord != nil {
+		return "discord:" + strconv.FormatUint(i.Discord.Id, 10)
+	}
+
+	return ""
+}
+
+// Returns the name of the person, preferring their in-game name.
+func (i ChatboxIdentity) Name() string {
+	if i.Ingame != nil {
+		return i.Ingame.Name
+	}
+	if i.Discord != nil {
+		return i.Discord.Name
+	}
+
+	return ""
+}
+
+// Reports whether the person has linked their in-game and Discord accounts.
+func (i ChatboxIdentity) Linked() bool {
+	return i.Ingame != nil && i.Discord != nil
+}
@@ -0,0 +1,74 @@
+package switchcraftgo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestChatboxDiscordRoles(t *testing.T) {
+	user := &ChatboxDiscordUser{
+		Roles: []*ChatboxDiscordRole{
+			{Id: 1, Name: "Moderator", Colour: 0xE74C3C},
+			{Id: 2, Name: "Member"},
+		},
+	}
+
+	if !user.HasRole(2) || user.HasRole(3) {
+		t.Fatalf("HasRole() returned the wrong result")
+	}
+	if !user.HasRoleNamed("moderator") || user.HasRoleNamed("Admin") {
+		t.Fatalf("HasRoleNamed() returned the wrong result")
+	}
+
+	highest := user.HighestRole()
+	if highest == nil || highest.Id != 1 {
+		t.Fatalf("expected highest role to be Moderator, got %+v", highest)
+	}
+	if highest.HexColour() != "#e74c3c" || highest.FormatColour() != ChatboxColourRed {
+		t.Fatalf("unexpected role colour %s, %c", highest.HexColour(), highest.FormatColour())
+	}
+	if user.Roles[1].HexColour() != "" || user.Roles[1].FormatColour() != ChatboxColourWhite {
+		t.Fatalf("expected role without colour to have no hex colour, and be white")
+	}
+
+	if (&ChatboxDiscordUser{}).HighestRole() != nil {
+		t.Fatalf("expected no highest role for a user without roles")
+	}
+}
+
+func TestNearestChatboxColour(t *testing.T) {
+	colours := map[uint32]ChatboxColour{
+		0x000000: ChatboxColourBlack,
+		0xFFAA00: ChatboxColourGold,
+		0x3498DB: ChatboxColourDarkAqua,
+		0x2ECC71: ChatboxColourGreen,
+		0xF1C40F: ChatboxColourGold,
+	}
+	for rgb, expected := range colours {
+		if colour := NearestChatboxColour(rgb); colour != expected {
+			t.Errorf("NearestChatboxColour(%06x): expected %c, got %c", rgb, expected, colour)
+		}
+	}
+}
+
+func TestChatboxIdentity(t *testing.T) {
+	var packet ChatboxDiscordChatPacket
+	err := json.Unmarshal([]byte(`{"discordUser": {"id": 1234, "name": "erb3", "linkedUser": {"name": "Erb3", "uuid": "abc"}}}`), &packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromDiscord := packet.DiscordUser.Identity()
+	fromIngame := (&ChatboxIngameUser{Name: "Erb3", Uuid: "abc"}).Identity()
+	if fromDiscord.Key() != "minecraft:abc" || fromDiscord.Key() != fromIngame.Key() {
+		t.Fatalf("expected both sides to resolve to minecraft:abc, got %q and %q", fromDiscord.Key(), fromIngame.Key())
+	}
+	if !fromDiscord.Linked() || fromIngame.Linked() || fromDiscord.Name() != "Erb3" {
+		t.Fatalf("unexpected identity %+v", fromDiscord)
+	}
+
+	unlinked := (&ChatboxDiscordUser{Id: 99, Name: "guest"}).Identity()
+	if unlinked.Key() != "discord:99" || unlinked.Name() != "guest" {
+		t.Fatalf("unexpected identity for unlinked user: %q", unlinked.Key())
+	}
+}
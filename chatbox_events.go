@@ -0,0 +1,236 @@
+package switchcraftgo
+
+import (
+	"context"
+	"sync"
+)
+
+// Event types sent by the chatbox server, as used with [Chatbox.On].
+const (
+	ChatboxEventCommand     = "command"
+	ChatboxEventJoin        = "join"
+	ChatboxEventLeave       = "leave"
+	ChatboxEventDeath       = "death"
+	ChatboxEventWorldChange = "world_change"
+	ChatboxEventAfk         = "afk"
+	ChatboxEventAfkReturn   = "afk_return"
+	ChatboxEventChatIngame  = "chat_ingame"
+	ChatboxEventChatDiscord = "chat_discord"
+	ChatboxEventChatChatbox = "chat_chatbox"
+
+	ChatboxEventServerRestartScheduled = "server_restart_scheduled"
+	ChatboxEventServerRestartCancelled = "server_restart_cancelled"
+
+	// Subscribes to every event type.
+	ChatboxEventAll = "*"
+)
+
+// An event received from the chatbox server, such as [ChatboxJoinPacket].
+// Use a type switch to get the packet.
+// Only the event packets of this package are events, so the type switch can cover every case.
+type ChatboxEvent interface {
+	// Returns the event type, such as [ChatboxEventJoin].
+	EventType() string
+	chatboxEvent()
+}
+
+// The packet types which are events, for use with [Subscribe].
+type ChatboxEventPacket interface {
+	ChatboxCommandPacket | ChatboxJoinPacket | ChatboxLeavePacket | ChatboxDeathPacket |
+		ChatboxWorldChangePacket | ChatboxAfkPacket | ChatboxAfkReturnPacket | ChatboxIngameChatPacket |
+		ChatboxDiscordChatPacket | ChatboxChatboxChatPacket |
+		ChatboxServerRestartScheduledPacket | ChatboxServerRestartCancelledPacket
+	ChatboxEvent
+}
+
+func (ChatboxCommandPacket) EventType() string     { return ChatboxEventCommand }
+func (ChatboxJoinPacket) EventType() string        { return ChatboxEventJoin }
+func (ChatboxLeavePacket) EventType() string       { return ChatboxEventLeave }
+func (ChatboxDeathPacket) EventType() string       { return ChatboxEventDeath }
+func (ChatboxWorldChangePacket) EventType() string { return ChatboxEventWorldChange }
+func (ChatboxAfkPacket) EventType() string         { return ChatboxEventAfk }
+func (ChatboxAfkReturnPacket) EventType() string   { return ChatboxEventAfkReturn }
+func (ChatboxIngameChatPacket) EventType() string  { return ChatboxEventChatIngame }
+func (ChatboxDiscordChatPacket) EventType() string { return ChatboxEventChatDiscord }
+func (ChatboxChatboxChatPacket) EventType() string { return ChatboxEventChatChatbox }
+
+func (ChatboxServerRestartScheduledPacket) EventType() string {
+	return ChatboxEventServerRestartScheduled
+}
+
+func (ChatboxServerRestartCancelledPacket) EventType() string {
+	return ChatboxEventServerRestartCancelled
+}
+
+func (ChatboxCommandPacket) chatboxEvent()                {}
+func (ChatboxJoinPacket) chatboxEvent()                   {}
+func (ChatboxLeavePacket) chatboxEvent()                  {}
+func (ChatboxDeathPacket) chatboxEvent()                  {}
+func (ChatboxWorldChangePacket) chatboxEvent()            {}
+func (ChatboxAfkPacket) chatboxEvent()                    {}
+func (ChatboxAfkReturnPacket) chatboxEvent()              {}
+func (ChatboxIngameChatPacket) chatboxEvent()             {}
+func (ChatboxDiscordChatPacket) chatboxEvent()            {}
+func (ChatboxChatboxChatPacket) chatboxEvent()            {}
+func (ChatboxServerRestartScheduledPacket) chatboxEvent() {}
+func (ChatboxServerRestartCancelledPacket) chatboxEvent() {}
+
+// Internal list of subscribers, per event type.
+type chatboxBus struct {
+	mu          sync.Mutex
+	lastId      int
+	subscribers map[string][]chatboxSubscriber
+}
+
+type chatboxSubscriber struct {
+	id      int
+	handler func(ChatboxEvent)
+}
+
+// Subscribes the handler to events of the type, such as [ChatboxEventJoin].
+// Use [ChatboxEventAll] to receive every event.
+// Any number of handlers can subscribe to the same event type.
+//
+// Handlers are called on the goroutine running [Chatbox.Listen], after the matching field handler
+// such as [Chatbox.OnJoin], in the order they subscribed. Handlers subscribed to [ChatboxEventAll] run last.
+// Returns a function that unsubscribes the handler, which is safe to call at any time, even from a handler.
+func (sc *Chatbox) On(event string, handler func(ChatboxEvent)) func() {
+	b := &sc.bus
+
+	b.mu.Lock()
+	b.lastId++
+	id := b.lastId
+	b.subscribers[event] = append(b.subscribers[event], chatboxSubscriber{id: id, handler: handler})
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		subscribers := b.subscribers[event]
+		for idx, sub := range subscribers {
+			if sub.id == id {
+				b.subscribers[event] = append(subscribers[:idx:idx], subscribers[idx+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Subscribes a handler for a single type of event, such as [ChatboxJoinPacket].
+// Works like [Chatbox.On], but the handler receives the packet type directly.
+func Subscribe[T ChatboxEventPacket](sc *Chatbox, handler func(T)) func() {
+	var event T
+
+	return sc.On(event.EventType(), func(ev ChatboxEvent) {
+		handler(ev.(T))
+	})
+}
+
+// Internal function to call the subscribers of the event.
+func (sc *Chatbox) emit(event ChatboxEvent) {
+	b := &sc.bus
+
+	b.mu.Lock()
+	var handlers []func(ChatboxEvent)
+	for _, sub := range b.subscribers[event.EventType()] {
+		handlers = append(handlers, sub.handler)
+	}
+	for _, sub := range b.subscribers[ChatboxEventAll] {
+		handlers = append(handlers, sub.handler)
+	}
+	b.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// What [Chatbox.Events] does with an event when the channel is full.
+type ChatboxOverflowPolicy int
+
+const (
+	// Discards the oldest event in the channel to make room. This is the default.
+	ChatboxOverflowDropOldest ChatboxOverflowPolicy = iota
+	// Discards the new event.
+	ChatboxOverflowDropNewest
+	// Waits until the consumer makes room.
+	// No further packets are handled by [Chatbox.Listen] while waiting.
+	ChatboxOverflowBlock
+)
+
+// Options for [Chatbox.Events].
+type ChatboxEventsOptions struct {
+	// Capacity of the channel. Defaults to 64.
+	Buffer   int
+	Overflow ChatboxOverflowPolicy
+}
+
+// Returns a channel receiving every event, for use in select loops and worker pools.
+// Use a type switch on the events to get the packets.
+//
+// The channel is closed once the context is done, or the chatbox is closed.
+// If the consumer falls behind, events are handled according to opts.Overflow.
+func (sc *Chatbox) Events(ctx context.Context, opts ChatboxEventsOptions) <-chan ChatboxEvent {
+	buffer := opts.Buffer
+	if buffer <= 0 {
+		buffer = 64
+	}
+
+	events := make(chan ChatboxEvent, buffer)
+
+	var mu sync.Mutex
+	var closed bool
+
+	unsubscribe := sc.On(ChatboxEventAll, func(ev ChatboxEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if closed {
+			return
+		}
+
+		switch opts.Overflow {
+		case ChatboxOverflowBlock:
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+			case <-sc.closeChan:
+			}
+		case ChatboxOverflowDropNewest:
+			select {
+			case events <- ev:
+			default:
+			}
+		default:
+			for {
+				select {
+				case events <- ev:
+					return
+				default:
+				}
+
+				select {
+				case <-events:
+				default:
+				}
+			}
+		}
+	})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-sc.closeChan:
+		}
+
+		unsubscribe()
+
+		mu.Lock()
+		closed = true
+		close(events)
+		mu.Unlock()
+	}()
+
+	return events
+}
@@ -0,0 +1,70 @@
+package switchcraftgo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestChatboxEventsOverflow(t *testing.T) {
+	policies := map[ChatboxOverflowPolicy][]string{
+		ChatboxOverflowDropOldest: {"3", "4"},
+		ChatboxOverflowDropNewest: {"1", "2"},
+	}
+
+	for policy, expected := range policies {
+		sc := newTestChatbox(t, func(conn *websocket.Conn) {
+			for _, name := range []string{"1", "2", "3", "4"} {
+				conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"join","user":{"type":"ingame","name":"`+name+`"}}`))
+			}
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		events := sc.Events(ctx, ChatboxEventsOptions{Buffer: 2, Overflow: policy})
+
+		if err := sc.Connect(); err != nil {
+			t.Fatalf("Connect() returned error %s", err.Error())
+		}
+		sc.Listen()
+		cancel()
+
+		var names []string
+		for ev := range events {
+			names = append(names, ev.(ChatboxJoinPacket).User.Name)
+		}
+
+		if len(names) != len(expected) || names[0] != expected[0] || names[1] != expected[1] {
+			t.Fatalf("overflow policy %d: expected events %v, got %v", policy, expected, names)
+		}
+	}
+}
+
+func TestChatboxEventsBlock(t *testing.T) {
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		for i := 0; i < 10; i++ {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"afk","user":{"type":"ingame","name":"Erb3"}}`))
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := sc.Events(ctx, ChatboxEventsOptions{Buffer: 1, Overflow: ChatboxOverflowBlock})
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	go sc.Listen()
+
+	for i := 0; i < 10; i++ {
+		select {
+		case ev := <-events:
+			if _, ok := ev.(ChatboxAfkPacket); !ok {
+				t.Fatalf("expected afk event, got %T", ev)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only received %d out of 10 events", i)
+		}
+	}
+}
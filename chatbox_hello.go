@@ -0,0 +1,110 @@
+package switchcraftgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Capabilities a chatbox license can have, as listed in [ChatboxHello].
+const (
+	ChatboxCapabilityRead    = "read"
+	ChatboxCapabilityCommand = "command"
+	ChatboxCapabilityTell    = "tell"
+	ChatboxCapabilitySay     = "say"
+)
+
+// Sent by the server after connecting, describing the license the chatbox is using.
+type ChatboxHello struct {
+	Type             string             `json:"type"`
+	Guest            bool               `json:"guest"`
+	LicenseOwner     string             `json:"licenseOwner"`
+	LicenseOwnerUser *ChatboxIngameUser `json:"licenseOwnerUser"`
+	Capabilities     []string           `json:"capabilities"`
+}
+
+// Reports whether the license has the capability, such as [ChatboxCapabilityTell].
+func (h *ChatboxHello) HasCapability(capability string) bool {
+	return slices.Contains(h.Capabilities, capability)
+}
+
+// Returned when the chatbox license does not have a capability needed to send a packet.
+// Matches [ErrChatboxMissingCapability] with [errors.Is].
+type ChatboxCapabilityError struct {
+	Capability string
+}
+
+var ErrChatboxMissingCapability = errors.New("chatbox license is missing a capability")
+
+func (e *ChatboxCapabilityError) Error() string {
+	return fmt.Sprintf("chatbox license is missing the %s capability", e.Capability)
+}
+
+func (e *ChatboxCapabilityError) Is(target error) bool {
+	return target == ErrChatboxMissingCapability
+}
+
+// Returns the hello packet of the current connection, or nil if it has not been received yet.
+func (sc *Chatbox) Hello() *ChatboxHello {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	return sc.hello
+}
+
+// Internal function to fail fast when the license is known to lack the capability.
+// Before the hello packet has been received, only the guest token is known to be read only.
+func (sc *Chatbox) checkCapability(capability string) error {
+	hello := sc.Hello()
+	if hello == nil {
+		if sc.token != "guest" || capability == ChatboxCapabilityRead {
+			return nil
+		}
+	} else if hello.HasCapability(capability) {
+		return nil
+	}
+
+	return &ChatboxCapabilityError{Capability: capability}
+}
+
+// Internal function to handle packets from a new connection, until the hello packet has been received.
+// Returns a [*ChatboxCloseError] if the server closed the connection instead.
+func (sc *Chatbox) awaitHello(ctx context.Context, conn *websocket.Conn) error {
+	deadline := time.Now().Add(sc.helloTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetReadDeadline(deadline)
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	for sc.Hello() == nil {
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			sc.mu.Lock()
+			closing := sc.closing
+			sc.mu.Unlock()
+
+			if closing != nil {
+				return &ChatboxCloseError{CloseReason: closing.CloseReason, Reason: closing.Reason}
+			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return fmt.Errorf("waiting for hello packet: %w", err)
+		}
+
+		sc.handleMessage(messageType, message)
+	}
+
+	return conn.SetReadDeadline(time.Time{})
+}
@@ -0,0 +1,96 @@
+package switchcraftgo
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestChatboxWaitForHello(t *testing.T) {
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"hello","guest":true,"licenseOwner":"Guest","capabilities":["read"]}`))
+		conn.ReadMessage()
+	})
+	sc.waitForHello = true
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	defer sc.Close()
+
+	hello := sc.Hello()
+	if hello == nil || !hello.Guest || !hello.HasCapability(ChatboxCapabilityRead) {
+		t.Fatalf("expected guest hello with read capability after connecting, got %+v", hello)
+	}
+
+	_, err := sc.Tell("Erb3", "hello", "Test", ChatboxFormattingMarkdown).Wait()
+	if !errors.Is(err, ErrChatboxMissingCapability) {
+		t.Fatalf("expected Tell() to fail with missing capability, got %v", err)
+	}
+
+	var capabilityErr *ChatboxCapabilityError
+	_, err = sc.Say("hello", "Test", ChatboxFormattingMarkdown).Wait()
+	if !errors.As(err, &capabilityErr) || capabilityErr.Capability != ChatboxCapabilitySay {
+		t.Fatalf("expected Say() to fail with missing say capability, got %v", err)
+	}
+}
+
+func TestChatboxGuestFailFast(t *testing.T) {
+	sc := NewChatbox(NewChatboxOptions{})
+	metrics := NewChatboxExpvarMetrics(fmt.Sprintf("chatbox_guest_test_%d", time.Now().UnixNano()))
+	sc.metrics = metrics
+
+	if _, err := sc.Tell("Erb3", "hello", "Test", ChatboxFormattingMarkdown).Wait(); !errors.Is(err, ErrChatboxMissingCapability) {
+		t.Fatalf("expected Tell() with the guest token to fail before the hello packet, got %v", err)
+	}
+
+	if _, err := sc.Say("hello", "Test", ChatboxFormattingMarkdown).Wait(); !errors.Is(err, ErrChatboxMissingCapability) {
+		t.Fatalf("expected Say() with the guest token to fail before the hello packet, got %v", err)
+	}
+
+	if metrics.Messages.Get("tell_failed").String() != "1" || metrics.Messages.Get("say_failed").String() != "1" {
+		t.Fatalf("expected failed messages to be counted, got %s", metrics.Messages.String())
+	}
+}
+
+func TestChatboxWaitForHelloClosing(t *testing.T) {
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		conn.WriteJSON(map[string]any{"ok": true, "type": "closing", "closeReason": "unknown_license_key", "reason": "Unknown license key"})
+	})
+	sc.waitForHello = true
+
+	var closeErr *ChatboxCloseError
+	if err := sc.Connect(); !errors.As(err, &closeErr) || closeErr.CloseReason != "unknown_license_key" {
+		t.Fatalf("expected Connect() to fail with close reason, got %v", err)
+	}
+}
+
+func TestChatboxWaitForHelloReconnectClosing(t *testing.T) {
+	var connections atomic.Int32
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		if connections.Add(1) == 1 {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"hello","guest":false,"licenseOwner":"Erb3","capabilities":["read"]}`))
+			return
+		}
+		conn.WriteJSON(map[string]any{"ok": true, "type": "closing", "closeReason": "invalid_license_key", "reason": "Invalid license key"})
+	})
+	sc.waitForHello = true
+	sc.reconnect = ChatboxReconnectOptions{Enabled: true, MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+
+	var closeErr *ChatboxCloseError
+	if err := sc.Listen(); !errors.As(err, &closeErr) || closeErr.CloseReason != "invalid_license_key" {
+		t.Fatalf("expected Listen() to stop with the close reason, got %v", err)
+	}
+
+	if connections.Load() != 2 {
+		t.Fatalf("expected to stop reconnecting after the fatal close reason, got %d connections", connections.Load())
+	}
+}
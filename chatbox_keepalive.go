@@ -0,0 +1,79 @@
+package switchcraftgo
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Returns the round trip time of the last keepalive ping, or zero if none has been answered.
+// Pings are only sent if [ChatboxConnectionOptions.PingInterval] is set.
+func (sc *Chatbox) Latency() time.Duration {
+	return time.Duration(sc.latency.Load())
+}
+
+// Internal function to ping the connection every ping interval, until stop is closed.
+// If a ping is not answered in time, stale is set and the connection is closed, which stops the listener.
+func (sc *Chatbox) keepalive(conn *websocket.Conn, pongs <-chan string, stale *atomic.Bool, stop <-chan struct{}) {
+	timeout := sc.connection.PongTimeout
+	if timeout <= 0 {
+		timeout = sc.connection.PingInterval
+	}
+
+	ticker := time.NewTicker(sc.connection.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		sent := time.Now()
+		payload := strconv.FormatInt(sent.UnixNano(), 10)
+		if err := conn.WriteControl(websocket.PingMessage, []byte(payload), sent.Add(timeout)); err != nil {
+			sc.logger.Warn("Failed to send keepalive ping", "error", err)
+			stale.Store(true)
+			conn.Close()
+			return
+		}
+
+		if !sc.awaitPong(pongs, payload, time.After(timeout), stop) {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			sc.logger.Warn("Chatbox connection is stale, keepalive ping was not answered", "timeout", timeout)
+			stale.Store(true)
+			conn.Close()
+			return
+		}
+
+		latency := time.Since(sent)
+		sc.latency.Store(int64(latency))
+		sc.metrics.PingLatency(latency)
+		sc.logger.Debug("Received keepalive pong", "latency", latency)
+	}
+}
+
+// Internal function to wait for the pong with the payload. Pongs to earlier pings are skipped.
+// Returns false if it timed out, or stop was closed.
+func (sc *Chatbox) awaitPong(pongs <-chan string, payload string, timeout <-chan time.Time, stop <-chan struct{}) bool {
+	for {
+		select {
+		case pong := <-pongs:
+			if pong == payload {
+				return true
+			}
+		case <-timeout:
+			return false
+		case <-stop:
+			return false
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package switchcraftgo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestChatboxKeepaliveLatency(t *testing.T) {
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		// Reading answers pings, until the client closes.
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	sc.connection.PingInterval = 10 * time.Millisecond
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+
+	listened := make(chan error, 1)
+	go func() { listened <- sc.Listen() }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for sc.Latency() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if sc.Latency() == 0 {
+		t.Fatalf("expected a latency to be measured")
+	}
+
+	sc.Close()
+	if err := <-listened; err != nil {
+		t.Fatalf("Listen() returned error %v after closing", err)
+	}
+}
+
+func TestChatboxKeepaliveStale(t *testing.T) {
+	done := make(chan struct{})
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		// Never reading means pings are never answered.
+		<-done
+	})
+	t.Cleanup(func() { close(done) })
+	sc.connection.PingInterval = 10 * time.Millisecond
+	sc.connection.PongTimeout = 50 * time.Millisecond
+
+	var disconnect error
+	sc.OnDisconnect = func(err error) { disconnect = err }
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+
+	if err := sc.Listen(); !errors.Is(err, ErrChatboxStale) {
+		t.Fatalf("expected Listen() to return ErrChatboxStale, got %v", err)
+	}
+	if !errors.Is(disconnect, ErrChatboxStale) {
+		t.Fatalf("expected OnDisconnect to be called with ErrChatboxStale, got %v", disconnect)
+	}
+}
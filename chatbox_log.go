@@ -0,0 +1,28 @@
+package switchcraftgo
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// Internal handler which discards every record, used when no logger is set.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// Internal function to get the chatbox url for logging, with the token hidden.
+func (sc *Chatbox) redactedUrl() string {
+	redacted := sc.scUrl
+	redacted.Path = strings.TrimSuffix(redacted.Path, sc.token) + "REDACTED"
+	return redacted.String()
+}
+
+// Internal function to log a decode error, and report it to [Chatbox.OnError].
+func (sc *Chatbox) reportDecodeError(err *ChatboxDecodeError) {
+	sc.logger.Warn("Failed to decode packet", "type", err.Type, "event", err.Event, "error", err.Err)
+	sc.OnError(err)
+}
@@ -0,0 +1,92 @@
+package switchcraftgo
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// A buffer which can be written to by several goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestChatboxLogging(t *testing.T) {
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"command","user":{"type":"ingame","name":"Erb3","uuid":"1234"},"command":"double","args":["two"],"ownerOnly":false}`))
+
+		var reply ChatboxTellPacket
+		conn.ReadJSON(&reply)
+	})
+
+	var output syncBuffer
+	sc.logger = slog.New(slog.NewTextHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	b := NewBrigadier(sc, "Test")
+	b.Register(b.Literal("double").Number("value").Executes(func(ev *BrigadierInvocation) {}))
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	sc.Listen()
+	logs := output.String()
+
+	if !strings.Contains(logs, `msg="Connected to chatbox"`) || !strings.Contains(logs, "/v2/REDACTED") {
+		t.Fatalf("expected connection to be logged with the token redacted, got:\n%s", logs)
+	}
+	if strings.Contains(logs, "/v2/test") {
+		t.Fatalf("token was logged:\n%s", logs)
+	}
+	if !strings.Contains(logs, `msg="Received packet" type=event event=command`) {
+		t.Fatalf("expected command event to be logged, got:\n%s", logs)
+	}
+	if !strings.Contains(logs, `msg="Rejected command" brigadier=Test command=double user=Erb3`) {
+		t.Fatalf("expected argument parse failure to be logged, got:\n%s", logs)
+	}
+}
+
+func TestBrigadierLogger(t *testing.T) {
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"command","user":{"type":"ingame","name":"Erb3","uuid":"1234"},"command":"double","args":["two"],"ownerOnly":false}`))
+
+		var reply ChatboxTellPacket
+		conn.ReadJSON(&reply)
+	})
+
+	var chatboxOutput, brigadierOutput syncBuffer
+	sc.logger = slog.New(slog.NewTextHandler(&chatboxOutput, nil))
+
+	b := NewBrigadierWithOptions(sc, "Test", NewBrigadierOptions{
+		Logger: slog.New(slog.NewTextHandler(&brigadierOutput, nil)),
+	})
+	b.Register(b.Literal("double").Number("value").Executes(func(ev *BrigadierInvocation) {}))
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	sc.Listen()
+
+	if !strings.Contains(brigadierOutput.String(), `msg="Rejected command" brigadier=Test`) {
+		t.Fatalf("expected rejection to be logged with the Brigadier logger, got:\n%s", brigadierOutput.String())
+	}
+	if strings.Contains(chatboxOutput.String(), "Rejected command") {
+		t.Fatalf("rejection was logged with the chatbox logger:\n%s", chatboxOutput.String())
+	}
+}
@@ -0,0 +1,168 @@
+package switchcraftgo
+
+import (
+	"expvar"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Receives measurements from a [Chatbox], and the [Brigadier]s using it, for monitoring.
+// Set with the Metrics option of [NewChatboxOptions].
+// Methods are called from several goroutines, so must be safe for concurrent use, and should return quickly.
+// See [ChatboxExpvarMetrics] for an implementation which publishes them with expvar.
+type ChatboxMetrics interface {
+	// Called for every packet received. The name is the event for event packets, such as "join",
+	// or else the packet type, such as "hello".
+	PacketReceived(name string)
+	// Called once the server has responded to a tell or say, or it could not be sent.
+	// Kind is "tell" or "say", and err is nil if it was sent. Split messages are counted per part.
+	MessageSent(kind string, err error)
+	// Called every time the chatbox has reconnected.
+	Reconnected()
+	// Called when the number of messages waiting in the queue changes.
+	QueueDepth(depth int)
+	// Called when a keepalive ping is answered, with its round trip time.
+	PingLatency(latency time.Duration)
+	// Called after a Brigadier handler returns, with how long it ran for.
+	// The command is the full path of the command, such as "echo" or "multiply help".
+	CommandHandled(command string, duration time.Duration)
+}
+
+// Internal metrics which discard every measurement, used when no metrics are set.
+type noopMetrics struct{}
+
+func (noopMetrics) PacketReceived(string)                {}
+func (noopMetrics) MessageSent(string, error)            {}
+func (noopMetrics) Reconnected()                         {}
+func (noopMetrics) QueueDepth(int)                       {}
+func (noopMetrics) PingLatency(time.Duration)            {}
+func (noopMetrics) CommandHandled(string, time.Duration) {}
+
+// Metrics which are published with expvar, so they are shown on /debug/vars.
+// Must be made with [NewChatboxExpvarMetrics].
+type ChatboxExpvarMetrics struct {
+	// Packets received, by event or packet type.
+	Packets *expvar.Map
+	// Messages sent, by kind and outcome, such as "tell_sent" and "say_failed".
+	Messages *expvar.Map
+	// Times the chatbox has reconnected.
+	Reconnects *expvar.Int
+	// Messages currently waiting in the queue.
+	Queue *expvar.Int
+	// Round trip time of the last keepalive ping, in milliseconds.
+	Latency *expvar.Float
+	// Latency histograms of Brigadier handlers, by command.
+	Commands *expvar.Map
+
+	commandsMu sync.Mutex
+}
+
+// Creates [ChatboxExpvarMetrics] and publishes them as a map with the name, such as "chatbox".
+// Like [expvar.Publish], it panics if the name is already used, so each chatbox needs a different name.
+func NewChatboxExpvarMetrics(name string) *ChatboxExpvarMetrics {
+	m := &ChatboxExpvarMetrics{
+		Packets:    new(expvar.Map).Init(),
+		Messages:   new(expvar.Map).Init(),
+		Reconnects: new(expvar.Int),
+		Queue:      new(expvar.Int),
+		Latency:    new(expvar.Float),
+		Commands:   new(expvar.Map).Init(),
+	}
+
+	published := new(expvar.Map).Init()
+	published.Set("packets", m.Packets)
+	published.Set("messages", m.Messages)
+	published.Set("reconnects", m.Reconnects)
+	published.Set("queue_depth", m.Queue)
+	published.Set("latency_ms", m.Latency)
+	published.Set("commands", m.Commands)
+	expvar.Publish(name, published)
+
+	return m
+}
+
+func (m *ChatboxExpvarMetrics) PacketReceived(name string) {
+	m.Packets.Add(name, 1)
+}
+
+func (m *ChatboxExpvarMetrics) MessageSent(kind string, err error) {
+	if err != nil {
+		m.Messages.Add(kind+"_failed", 1)
+	} else {
+		m.Messages.Add(kind+"_sent", 1)
+	}
+}
+
+func (m *ChatboxExpvarMetrics) Reconnected() {
+	m.Reconnects.Add(1)
+}
+
+func (m *ChatboxExpvarMetrics) QueueDepth(depth int) {
+	m.Queue.Set(int64(depth))
+}
+
+func (m *ChatboxExpvarMetrics) PingLatency(latency time.Duration) {
+	m.Latency.Set(float64(latency) / float64(time.Millisecond))
+}
+
+func (m *ChatboxExpvarMetrics) CommandHandled(command string, duration time.Duration) {
+	m.commandsMu.Lock()
+	histogram, ok := m.Commands.Get(command).(*latencyHistogram)
+	if !ok {
+		histogram = &latencyHistogram{}
+		m.Commands.Set(command, histogram)
+	}
+	m.commandsMu.Unlock()
+
+	histogram.observe(duration)
+}
+
+// Upper bounds of the latency histogram buckets. Longer durations are only counted in the total.
+var latencyBuckets = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// Internal histogram of durations, which is an [expvar.Var].
+// Buckets are cumulative, counting every duration up to their bound.
+type latencyHistogram struct {
+	mu      sync.Mutex
+	count   int64
+	sum     time.Duration
+	buckets [len(latencyBuckets)]int64
+}
+
+func (h *latencyHistogram) observe(duration time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.count++
+	h.sum += duration
+	for idx, bound := range latencyBuckets {
+		if duration <= bound {
+			h.buckets[idx]++
+		}
+	}
+}
+
+// Returns the histogram as JSON, such as {"count": 2, "sum_ms": 3.5, "buckets": {"1ms": 1, ...}}.
+func (h *latencyHistogram) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buckets := make([]string, len(latencyBuckets))
+	for idx, bound := range latencyBuckets {
+		buckets[idx] = fmt.Sprintf("%q: %d", bound.String(), h.buckets[idx])
+	}
+
+	return fmt.Sprintf(`{"count": %d, "sum_ms": %g, "buckets": {%s}}`,
+		h.count, float64(h.sum)/float64(time.Millisecond), strings.Join(buckets, ", "))
+}
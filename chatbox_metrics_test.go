@@ -0,0 +1,68 @@
+package switchcraftgo
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestChatboxExpvarMetrics(t *testing.T) {
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"hello","guest":false,"licenseOwner":"Erb3","capabilities":["read","command","tell"]}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"command","user":{"type":"ingame","name":"Erb3","uuid":"1234"},"command":"ping","args":["pong"],"ownerOnly":false}`))
+
+		var reply ChatboxTellPacket
+		conn.ReadJSON(&reply)
+		conn.WriteJSON(map[string]any{"ok": true, "type": "success", "id": reply.Id, "reason": "message_queued"})
+	})
+
+	name := fmt.Sprintf("chatbox_test_%d", time.Now().UnixNano())
+	metrics := NewChatboxExpvarMetrics(name)
+	sc.metrics = metrics
+
+	b := NewBrigadier(sc, "Test")
+	b.Register(b.Literal("ping").Then(b.Literal("pong").Executes(func(ev *BrigadierInvocation) {
+		ev.Reply("pong")
+	})))
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	sc.Listen()
+
+	if metrics.Packets.Get("hello").String() != "1" || metrics.Packets.Get("command").String() != "1" || metrics.Packets.Get("success").String() != "1" {
+		t.Fatalf("unexpected packet counts %s", metrics.Packets.String())
+	}
+	if metrics.Messages.Get("tell_sent").String() != "1" {
+		t.Fatalf("unexpected message counts %s", metrics.Messages.String())
+	}
+
+	histogram := metrics.Commands.Get("ping pong")
+	if histogram == nil {
+		t.Fatalf("expected a histogram for ping pong, got %s", metrics.Commands.String())
+	}
+
+	var published map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &published); err != nil {
+		t.Fatalf("published metrics are not valid JSON: %s", err.Error())
+	}
+	if !strings.Contains(string(published["commands"]), `"count": 1`) {
+		t.Fatalf("expected one command in published metrics, got %s", published["commands"])
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	var histogram latencyHistogram
+	histogram.observe(3 * time.Millisecond)
+	histogram.observe(2 * time.Second)
+
+	expected := `{"count": 2, "sum_ms": 2003, "buckets": {"1ms": 0, "5ms": 1, "10ms": 1, "50ms": 1, "100ms": 1, "500ms": 1, "1s": 1, "5s": 2}}`
+	if histogram.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, histogram.String())
+	}
+}
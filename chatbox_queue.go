@@ -0,0 +1,301 @@
+package switchcraftgo
+
+import (
+	"sync"
+	"time"
+)
+
+// Options for the outbound message queue of a [Chatbox].
+//
+// Messages are sent at a rate limited by a token bucket, which holds up to Burst tokens,
+// and is refilled with Rate tokens per second. Each message uses one token.
+// Recipients take turns, so that one user receiving many messages does not delay everyone else.
+type ChatboxQueueOptions struct {
+	// Tokens added per second. Defaults to 2. A negative rate disables the rate limit.
+	Rate float64
+	// Size of the bucket. Defaults to 5.
+	Burst int
+	// Whether [Chatbox.Close] waits for queued messages to be sent.
+	DrainOnClose bool
+	// Max time [Chatbox.Close] waits for queued messages. Defaults to 10 seconds.
+	DrainTimeout time.Duration
+}
+
+// A packet waiting in the queue.
+type queuedPacket struct {
+	recipient string
+	result    *ChatboxResult
+	packet    any
+}
+
+// Internal outbound queue, with a priority lane and a lane per recipient.
+type chatboxQueue struct {
+	opts   ChatboxQueueOptions
+	bucket tokenBucket
+
+	mu       sync.Mutex
+	priority []*queuedPacket
+	lanes    map[string][]*queuedPacket
+	order    []string
+	depth    int
+	sending  bool
+	closed   error
+	wake     chan struct{}
+	idle     chan struct{}
+}
+
+func newChatboxQueue(opts ChatboxQueueOptions) *chatboxQueue {
+	if opts.Rate == 0 {
+		opts.Rate = 2
+	}
+
+	if opts.Burst <= 0 {
+		opts.Burst = 5
+	}
+
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = 10 * time.Second
+	}
+
+	idle := make(chan struct{})
+	close(idle)
+
+	return &chatboxQueue{
+		opts: opts,
+		bucket: tokenBucket{
+			rate:   opts.Rate,
+			burst:  float64(opts.Burst),
+			tokens: float64(opts.Burst),
+		},
+		lanes: map[string][]*queuedPacket{},
+		wake:  make(chan struct{}, 1),
+		idle:  idle,
+	}
+}
+
+// Returns the number of messages waiting to be sent.
+func (sc *Chatbox) QueueDepth() int {
+	sc.queue.mu.Lock()
+	defer sc.queue.mu.Unlock()
+
+	return sc.queue.depth
+}
+
+// Internal function to queue packets for sending, one after another.
+// Priority packets are sent before all others, and are used for error replies.
+// Once the chatbox is closed, the results are failed with [ErrChatboxClosed].
+func (sc *Chatbox) enqueue(items []*queuedPacket, priority bool) {
+	if sc.isClosed() {
+		for _, item := range items {
+			item.result.complete("", ErrChatboxClosed)
+		}
+		return
+	}
+
+	sc.queueOnce.Do(func() {
+		go sc.runQueue()
+	})
+
+	if sc.queue.push(items, priority) {
+		sc.metrics.QueueDepth(sc.QueueDepth())
+	}
+}
+
+// Internal function that sends queued packets, until the chatbox is closed.
+func (sc *Chatbox) runQueue() {
+	q := sc.queue
+
+	for {
+		item := q.pop()
+		if item == nil {
+			select {
+			case <-q.wake:
+				continue
+			case <-sc.closeChan:
+				return
+			}
+		}
+		sc.metrics.QueueDepth(sc.QueueDepth())
+
+		if wait := q.bucket.reserve(time.Now()); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-sc.closeChan:
+				item.result.complete("", ErrChatboxClosed)
+				q.finish()
+				return
+			}
+		}
+
+		if !sc.holdForRestart() || !sc.awaitReconnect() {
+			item.result.complete("", ErrChatboxClosed)
+			q.finish()
+			return
+		}
+
+		sc.logger.Debug("Sending packet", "id", item.result.Id, "recipient", item.recipient)
+		sc.write(item.result, item.packet)
+		q.finish()
+	}
+}
+
+// Adds packets to the queue. Returns false if the queue has been closed by [chatboxQueue.failAll],
+// in which case the results are failed with its error instead.
+func (q *chatboxQueue) push(items []*queuedPacket, priority bool) bool {
+	q.mu.Lock()
+	if err := q.closed; err != nil {
+		q.mu.Unlock()
+		for _, item := range items {
+			item.result.complete("", err)
+		}
+		return false
+	}
+
+	if q.depth == 0 && !q.sending {
+		q.idle = make(chan struct{})
+	}
+
+	for _, item := range items {
+		if priority {
+			q.priority = append(q.priority, item)
+		} else {
+			if len(q.lanes[item.recipient]) == 0 {
+				q.order = append(q.order, item.recipient)
+			}
+			q.lanes[item.recipient] = append(q.lanes[item.recipient], item)
+		}
+		q.depth++
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return true
+}
+
+// Takes the next packet to send, or nil if the queue is empty.
+// [chatboxQueue.finish] must be called once the packet has been sent.
+func (q *chatboxQueue) pop() *queuedPacket {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var item *queuedPacket
+	if len(q.priority) != 0 {
+		item = q.priority[0]
+		q.priority = q.priority[1:]
+	} else if len(q.order) != 0 {
+		recipient := q.order[0]
+		q.order = q.order[1:]
+
+		lane := q.lanes[recipient]
+		item = lane[0]
+		if len(lane) == 1 {
+			delete(q.lanes, recipient)
+		} else {
+			q.lanes[recipient] = lane[1:]
+			q.order = append(q.order, recipient)
+		}
+	}
+
+	if item != nil {
+		q.depth--
+		q.sending = true
+	}
+
+	return item
+}
+
+func (q *chatboxQueue) finish() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.sending = false
+	if q.depth == 0 {
+		close(q.idle)
+	}
+}
+
+// Internal function to wait until every queued packet has been sent and responded to,
+// or the drain timeout has passed.
+func (sc *Chatbox) drainQueue() {
+	timeout := time.After(sc.queue.opts.DrainTimeout)
+
+	sc.queue.mu.Lock()
+	idle := sc.queue.idle
+	sc.queue.mu.Unlock()
+
+	select {
+	case <-idle:
+	case <-timeout:
+		return
+	}
+
+	sc.mu.Lock()
+	var pending []*ChatboxResult
+	for _, result := range sc.pending {
+		pending = append(pending, result)
+	}
+	sc.mu.Unlock()
+
+	for _, result := range pending {
+		select {
+		case <-result.Done():
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// Removes every queued packet, failing the results with the error.
+// The queue is closed, so packets pushed afterwards are failed with the error too.
+func (q *chatboxQueue) failAll(err error) {
+	q.mu.Lock()
+	q.closed = err
+	var items []*queuedPacket
+	items = append(items, q.priority...)
+	for _, lane := range q.lanes {
+		items = append(items, lane...)
+	}
+	q.priority = nil
+	q.lanes = map[string][]*queuedPacket{}
+	q.order = nil
+	if q.depth != 0 && !q.sending {
+		close(q.idle)
+	}
+	q.depth = 0
+	q.mu.Unlock()
+
+	for _, item := range items {
+		item.result.complete("", err)
+	}
+}
+
+// Internal token bucket rate limiter. Only used by the queue goroutine.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// Takes a token, and returns how long to wait before it may be used.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if b.rate < 0 {
+		return 0
+	}
+
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,122 @@
+package switchcraftgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestChatboxQueueOrder(t *testing.T) {
+	q := newChatboxQueue(ChatboxQueueOptions{})
+	push := func(recipient string, id int, priority bool) {
+		q.push([]*queuedPacket{{recipient: recipient, result: &ChatboxResult{Id: id}}}, priority)
+	}
+
+	push("spammy", 1, false)
+	push("spammy", 2, false)
+	push("spammy", 3, false)
+	push("quiet", 4, false)
+	push("spammy", 5, true)
+
+	if q.depth != 5 {
+		t.Fatalf("expected queue depth 5, got %d", q.depth)
+	}
+
+	var order []int
+	for item := q.pop(); item != nil; item = q.pop() {
+		order = append(order, item.result.Id)
+		q.finish()
+	}
+
+	expected := []int{5, 1, 4, 2, 3}
+	for idx, id := range expected {
+		if order[idx] != id {
+			t.Fatalf("expected packets in order %v, got %v", expected, order)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := tokenBucket{rate: 2, burst: 2, tokens: 2}
+	now := time.Now()
+
+	if b.reserve(now) != 0 || b.reserve(now) != 0 {
+		t.Fatalf("expected burst to be sent without waiting")
+	}
+
+	if wait := b.reserve(now); wait != 500*time.Millisecond {
+		t.Fatalf("expected to wait 500ms once the bucket is empty, got %s", wait)
+	}
+
+	if wait := b.reserve(now.Add(time.Second)); wait != 0 {
+		t.Fatalf("expected bucket to refill, got wait of %s", wait)
+	}
+}
+
+func TestChatboxDrainOnClose(t *testing.T) {
+	received := make(chan ChatboxTellPacket, 3)
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		for {
+			var packet ChatboxTellPacket
+			if err := conn.ReadJSON(&packet); err != nil {
+				return
+			}
+			received <- packet
+			conn.WriteJSON(map[string]any{"ok": true, "type": "success", "id": packet.Id, "reason": "message_queued"})
+		}
+	})
+	sc.queue = newChatboxQueue(ChatboxQueueOptions{Rate: 50, Burst: 1, DrainOnClose: true})
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	go sc.Listen()
+
+	results := []*ChatboxResult{
+		sc.Tell("Erb3", "one", "Test", ChatboxFormattingMarkdown),
+		sc.Tell("Erb3", "two", "Test", ChatboxFormattingMarkdown),
+		sc.Tell("Erb3", "three", "Test", ChatboxFormattingMarkdown),
+	}
+	sc.Close()
+
+	for _, result := range results {
+		if _, err := result.Wait(); err != nil {
+			t.Fatalf("expected queued message to be sent before closing, got error %v", err)
+		}
+	}
+
+	if len(received) != 3 {
+		t.Fatalf("expected all 3 queued messages to be received, got %d", len(received))
+	}
+
+	if depth := sc.QueueDepth(); depth != 0 {
+		t.Fatalf("expected empty queue after closing, got depth %d", depth)
+	}
+
+	if _, err := sc.Tell("Erb3", "four", "Test", ChatboxFormattingMarkdown).Wait(); err != ErrChatboxClosed {
+		t.Fatalf("expected Tell() after Close() to fail with ErrChatboxClosed, got %v", err)
+	}
+}
+
+func TestChatboxQueuePushAfterFailAll(t *testing.T) {
+	q := newChatboxQueue(ChatboxQueueOptions{})
+	q.failAll(ErrChatboxClosed)
+
+	result := &ChatboxResult{Id: 1, done: make(chan struct{})}
+	if q.push([]*queuedPacket{{recipient: "Erb3", result: result}}, false) {
+		t.Fatalf("expected push() to a closed queue to return false")
+	}
+
+	select {
+	case <-result.Done():
+	default:
+		t.Fatalf("expected result pushed to a closed queue to be completed")
+	}
+	if _, err := result.Wait(); err != ErrChatboxClosed {
+		t.Fatalf("expected ErrChatboxClosed, got %v", err)
+	}
+	if q.depth != 0 {
+		t.Fatalf("expected closed queue to stay empty, got depth %d", q.depth)
+	}
+}
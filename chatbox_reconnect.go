@@ -0,0 +1,121 @@
+package switchcraftgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Options for automatically reconnecting when the chatbox connection is lost.
+// Reconnecting is opt-in, and is enabled by setting Enabled.
+//
+// The delay between attempts starts at MinDelay and doubles after each failed attempt, up to MaxDelay.
+// Each delay is randomly shortened by up to half, so that many bots do not reconnect at the same time.
+// Messages sent while reconnecting wait in the queue, and are sent once reconnected.
+type ChatboxReconnectOptions struct {
+	Enabled bool
+	// Defaults to one second.
+	MinDelay time.Duration
+	// Defaults to one minute.
+	MaxDelay time.Duration
+	// Number of attempts before giving up. Zero means that it retries forever.
+	MaxAttempts int
+}
+
+// Close reasons sent in the closing packet, where reconnecting will fail in the same way.
+var fatalCloseReasons = map[string]bool{
+	"external_guests_not_allowed": true,
+	"unknown_license_key":         true,
+	"invalid_license_key":         true,
+	"disabled_license":            true,
+	"changed_license_key":         true,
+	"unsupported_endpoint":        true,
+}
+
+// Internal function to reconnect with backoff.
+// Returns nil once reconnected, or if the chatbox was closed while reconnecting.
+// Returns the last error if it ran out of attempts, or a [*ChatboxCloseError] if the server
+// closed the connection for a reason that will not go away by retrying.
+func (sc *Chatbox) reconnectLoop(ctx context.Context) error {
+	var err error
+
+	for attempt := 1; sc.reconnect.MaxAttempts == 0 || attempt <= sc.reconnect.MaxAttempts; attempt++ {
+		delay := sc.reconnect.delay(attempt)
+		if attempt == 1 {
+			delay = max(delay, sc.untilRestart())
+		}
+		sc.logger.Info("Reconnecting to chatbox", "attempt", attempt, "delay", delay)
+		sc.OnReconnecting(attempt, delay)
+
+		select {
+		case <-time.After(delay):
+		case <-sc.closeChan:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if err = sc.ConnectContext(ctx); err != nil {
+			var closeErr *ChatboxCloseError
+			if errors.As(err, &closeErr) && fatalCloseReasons[closeErr.CloseReason] {
+				sc.logger.Error("Stopped reconnecting to chatbox", "close_reason", closeErr.CloseReason, "error", err)
+				return err
+			}
+			continue
+		}
+
+		if sc.isClosed() {
+			sc.getConn().Close()
+			return nil
+		}
+
+		sc.metrics.Reconnected()
+		sc.OnReconnect()
+		return nil
+	}
+
+	sc.logger.Error("Gave up reconnecting to chatbox", "attempts", sc.reconnect.MaxAttempts, "error", err)
+	return fmt.Errorf("gave up reconnecting after %d attempts: %w", sc.reconnect.MaxAttempts, err)
+}
+
+// Internal function to block while the chatbox is reconnecting.
+// Returns false if the chatbox was closed while waiting.
+func (sc *Chatbox) awaitReconnect() bool {
+	sc.mu.Lock()
+	reconnecting := sc.reconnecting
+	sc.mu.Unlock()
+
+	if reconnecting == nil {
+		return true
+	}
+
+	select {
+	case <-reconnecting:
+		return true
+	case <-sc.closeChan:
+		return false
+	}
+}
+
+// Internal function to get the delay before the attempt, including jitter.
+func (opts ChatboxReconnectOptions) delay(attempt int) time.Duration {
+	minDelay := opts.MinDelay
+	if minDelay <= 0 {
+		minDelay = time.Second
+	}
+
+	maxDelay := opts.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = time.Minute
+	}
+
+	delay := minDelay
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+
+	return delay/2 + rand.N(delay/2+1)
+}
@@ -0,0 +1,127 @@
+package switchcraftgo
+
+import "time"
+
+// Sent when a server restart has been scheduled.
+// RestartType is either "automatic" or "manual".
+type ChatboxServerRestartScheduledPacket struct {
+	Event          string           `json:"event"`
+	RestartType    string           `json:"restartType"`
+	RestartSeconds int              `json:"restartSeconds"`
+	RestartAt      ChatboxTimestamp `json:"restartAt"`
+	Time           ChatboxTimestamp `json:"time"`
+}
+
+// Sent when a scheduled server restart has been cancelled.
+type ChatboxServerRestartCancelledPacket struct {
+	Event       string           `json:"event"`
+	RestartType string           `json:"restartType"`
+	Time        ChatboxTimestamp `json:"time"`
+}
+
+// How long before a scheduled restart [NewChatboxOptions.HoldDuringRestart] starts holding messages.
+const restartHoldMargin = 5 * time.Second
+
+// How long after a scheduled restart messages are held for, if the restart is not seen to happen,
+// such as when it was delayed, or the connection was never dropped.
+const restartHoldGrace = 2 * time.Minute
+
+// Internal state of a scheduled restart.
+// cleared is closed once the restart is over, or has been cancelled.
+type restartState struct {
+	at      time.Time
+	cleared chan struct{}
+}
+
+// Reports whether a server restart is scheduled, and when.
+// The restart stays pending until it is cancelled, or the chatbox has reconnected after it.
+func (sc *Chatbox) RestartPending() (time.Time, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.restart == nil {
+		return time.Time{}, false
+	}
+
+	return sc.restart.at, true
+}
+
+// Internal function to mark a restart as pending.
+func (sc *Chatbox) scheduleRestart(packet ChatboxServerRestartScheduledPacket) {
+	at := packet.RestartAt.Time
+	if at.IsZero() {
+		at = time.Now().Add(time.Duration(packet.RestartSeconds) * time.Second)
+	}
+	sc.logger.Info("Server restart scheduled", "restart_type", packet.RestartType, "at", at)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.restart == nil {
+		sc.restart = &restartState{cleared: make(chan struct{})}
+	}
+	sc.restart.at = at
+}
+
+// Internal function to clear the pending restart.
+// If onlyPassed is set, it is only cleared if the restart time has passed.
+func (sc *Chatbox) clearRestart(onlyPassed bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.restart == nil || (onlyPassed && time.Now().Before(sc.restart.at)) {
+		return
+	}
+
+	close(sc.restart.cleared)
+	sc.restart = nil
+}
+
+// Internal function to block while a restart is about to happen, or happening.
+// Does nothing unless [NewChatboxOptions.HoldDuringRestart] is set.
+// Messages are no longer held once [restartHoldGrace] has passed after the restart time.
+// Returns false if the chatbox was closed while waiting.
+func (sc *Chatbox) holdForRestart() bool {
+	if !sc.holdRestart {
+		return true
+	}
+
+	for {
+		sc.mu.Lock()
+		restart := sc.restart
+		sc.mu.Unlock()
+
+		if restart == nil || time.Until(restart.at) > restartHoldMargin {
+			return true
+		}
+
+		expired := time.Until(restart.at.Add(restartHoldGrace))
+		if expired <= 0 {
+			return true
+		}
+
+		select {
+		case <-restart.cleared:
+		case <-time.After(expired):
+			sc.logger.Warn("Stopped holding messages for server restart", "at", restart.at)
+			return true
+		case <-sc.closeChan:
+			return false
+		}
+	}
+}
+
+// Internal function to get how long to wait before reconnecting, so that
+// the chatbox does not reconnect to a server which is about to restart.
+func (sc *Chatbox) untilRestart() time.Duration {
+	if !sc.holdRestart {
+		return 0
+	}
+
+	at, ok := sc.RestartPending()
+	if !ok {
+		return 0
+	}
+
+	return max(time.Until(at), 0)
+}
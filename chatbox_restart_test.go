@@ -0,0 +1,88 @@
+package switchcraftgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestChatboxRestartPending(t *testing.T) {
+	restartAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339Nano)
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"server_restart_scheduled","restartType":"automatic","restartSeconds":3600,"restartAt":"`+restartAt+`"}`))
+		conn.ReadMessage()
+	})
+
+	scheduled := make(chan ChatboxServerRestartScheduledPacket, 1)
+	sc.OnServerRestartScheduled = func(p ChatboxServerRestartScheduledPacket) { scheduled <- p }
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	go sc.Listen()
+	defer sc.Close()
+
+	packet := <-scheduled
+	if packet.RestartType != "automatic" || packet.RestartSeconds != 3600 {
+		t.Fatalf("restart event was not decoded, got %+v", packet)
+	}
+
+	at, ok := sc.RestartPending()
+	if !ok || !at.Equal(packet.RestartAt.Time) {
+		t.Fatalf("expected restart pending at %s, got %s (pending: %t)", packet.RestartAt.Time, at, ok)
+	}
+
+	sc.clearRestart(true)
+	if _, ok := sc.RestartPending(); !ok {
+		t.Fatalf("restart was cleared before the restart time")
+	}
+
+	sc.clearRestart(false)
+	if _, ok := sc.RestartPending(); ok {
+		t.Fatalf("restart was not cleared after being cancelled")
+	}
+}
+
+func TestChatboxHoldDuringRestart(t *testing.T) {
+	sc := NewChatbox(NewChatboxOptions{HoldDuringRestart: true})
+	sc.scheduleRestart(ChatboxServerRestartScheduledPacket{RestartSeconds: 1})
+
+	held := make(chan bool)
+	go func() { held <- sc.holdForRestart() }()
+
+	select {
+	case <-held:
+		t.Fatalf("messages were not held right before the restart")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	sc.clearRestart(false)
+	if !<-held {
+		t.Fatalf("holding reported the chatbox as closed")
+	}
+}
+
+func TestChatboxHoldDuringRestartExpires(t *testing.T) {
+	sc := NewChatbox(NewChatboxOptions{HoldDuringRestart: true})
+	sc.restart = &restartState{
+		at:      time.Now().Add(100*time.Millisecond - restartHoldGrace),
+		cleared: make(chan struct{}),
+	}
+
+	held := make(chan bool)
+	go func() { held <- sc.holdForRestart() }()
+
+	select {
+	case ok := <-held:
+		if !ok {
+			t.Fatalf("holding reported the chatbox as closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("messages were still held after the grace period had passed")
+	}
+
+	if _, ok := sc.RestartPending(); !ok {
+		t.Fatalf("expected restart to stay pending until it is cleared")
+	}
+}
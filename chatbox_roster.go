@@ -0,0 +1,254 @@
+package switchcraftgo
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The players online, kept up to date from the players packet and player events.
+// Get it with [Chatbox.Roster]. Safe for concurrent use.
+//
+// Players are returned as copies, including their linked Discord user, so changing them does not change the roster.
+type ChatboxRoster struct {
+	mu          sync.RWMutex
+	players     map[string]ChatboxIngameUser
+	lastId      int
+	subscribers []rosterSubscriber
+}
+
+type rosterSubscriber struct {
+	id      int
+	handler func(ChatboxRosterChange)
+}
+
+// The kind of change made to a [ChatboxRoster].
+type ChatboxRosterChangeType int
+
+const (
+	// The roster was replaced by a players packet, such as after connecting.
+	ChatboxRosterReset ChatboxRosterChangeType = iota
+	ChatboxRosterJoin
+	ChatboxRosterLeave
+	// A player changed world, went AFK, or returned from being AFK.
+	ChatboxRosterUpdate
+)
+
+// A change made to a [ChatboxRoster].
+// User is the player after the change, or before it for leaves. It is empty for resets.
+type ChatboxRosterChange struct {
+	Type ChatboxRosterChangeType
+	User ChatboxIngameUser
+}
+
+// Sent by the server after connecting, with every player online.
+type ChatboxPlayersPacket struct {
+	Type    string              `json:"type"`
+	Time    ChatboxTimestamp    `json:"time"`
+	Players []ChatboxIngameUser `json:"players"`
+}
+
+func newChatboxRoster() *ChatboxRoster {
+	return &ChatboxRoster{
+		players: map[string]ChatboxIngameUser{},
+	}
+}
+
+// Returns the roster of players online.
+// It is empty until the server has sent the players packet after connecting.
+func (sc *Chatbox) Roster() *ChatboxRoster {
+	return sc.roster
+}
+
+// Returns the online player with the UUID.
+func (r *ChatboxRoster) Get(uuid string) (ChatboxIngameUser, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.players[uuid]
+	return user.clone(), ok
+}
+
+// Returns the online player with the name, ignoring case.
+func (r *ChatboxRoster) ByName(name string) (ChatboxIngameUser, bool) {
+	return r.find(func(user ChatboxIngameUser) bool {
+		return strings.EqualFold(user.Name, name)
+	})
+}
+
+// Returns the online player with the display name, ignoring case.
+func (r *ChatboxRoster) ByDisplayName(displayName string) (ChatboxIngameUser, bool) {
+	return r.find(func(user ChatboxIngameUser) bool {
+		return strings.EqualFold(user.DisplayName, displayName)
+	})
+}
+
+// Returns the number of players online.
+func (r *ChatboxRoster) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.players)
+}
+
+// Returns every player online, sorted by name.
+func (r *ChatboxRoster) Players() []ChatboxIngameUser {
+	r.mu.RLock()
+	players := make([]ChatboxIngameUser, 0, len(r.players))
+	for _, user := range r.players {
+		players = append(players, user.clone())
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(players, func(i, j int) bool {
+		return strings.ToLower(players[i].Name) < strings.ToLower(players[j].Name)
+	})
+
+	return players
+}
+
+// Calls fn for every player online, sorted by name, until it returns false.
+func (r *ChatboxRoster) Range(fn func(ChatboxIngameUser) bool) {
+	for _, user := range r.Players() {
+		if !fn(user) {
+			return
+		}
+	}
+}
+
+// Calls the handler whenever the roster changes, on the goroutine running [Chatbox.Listen].
+// Returns a function that unsubscribes the handler.
+func (r *ChatboxRoster) OnChange(handler func(ChatboxRosterChange)) func() {
+	r.mu.Lock()
+	r.lastId++
+	id := r.lastId
+	r.subscribers = append(r.subscribers, rosterSubscriber{id: id, handler: handler})
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		for idx, sub := range r.subscribers {
+			if sub.id == id {
+				r.subscribers = append(r.subscribers[:idx:idx], r.subscribers[idx+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Internal function to find the first player matching the function.
+func (r *ChatboxRoster) find(match func(ChatboxIngameUser) bool) (ChatboxIngameUser, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.players {
+		if match(user) {
+			return user.clone(), true
+		}
+	}
+
+	return ChatboxIngameUser{}, false
+}
+
+// Internal function to replace every player, from a players packet.
+func (r *ChatboxRoster) reset(players []ChatboxIngameUser) {
+	r.mu.Lock()
+	r.players = make(map[string]ChatboxIngameUser, len(players))
+	for _, user := range players {
+		r.players[user.Uuid] = user.clone()
+	}
+	r.mu.Unlock()
+
+	r.notify(ChatboxRosterChange{Type: ChatboxRosterReset})
+}
+
+// Internal function to update the roster from a player event.
+// Other events are ignored.
+func (r *ChatboxRoster) apply(event ChatboxEvent) {
+	var change ChatboxRosterChange
+
+	r.mu.Lock()
+	switch ev := event.(type) {
+	case ChatboxJoinPacket:
+		change = ChatboxRosterChange{Type: ChatboxRosterJoin, User: ev.User}
+		r.players[ev.User.Uuid] = ev.User.clone()
+	case ChatboxLeavePacket:
+		user, ok := r.players[ev.User.Uuid]
+		if !ok {
+			user = ev.User
+		}
+
+		change = ChatboxRosterChange{Type: ChatboxRosterLeave, User: user}
+		delete(r.players, ev.User.Uuid)
+	case ChatboxWorldChangePacket:
+		user := ev.User
+		user.World = ev.Destination
+
+		change = ChatboxRosterChange{Type: ChatboxRosterUpdate, User: user}
+		r.players[user.Uuid] = user.clone()
+	case ChatboxAfkPacket:
+		user := ev.User
+		user.Afk = true
+
+		change = ChatboxRosterChange{Type: ChatboxRosterUpdate, User: user}
+		r.players[user.Uuid] = user.clone()
+	case ChatboxAfkReturnPacket:
+		user := ev.User
+		user.Afk = false
+
+		change = ChatboxRosterChange{Type: ChatboxRosterUpdate, User: user}
+		r.players[user.Uuid] = user.clone()
+	default:
+		r.mu.Unlock()
+		return
+	}
+	r.mu.Unlock()
+
+	r.notify(change)
+}
+
+func (r *ChatboxRoster) notify(change ChatboxRosterChange) {
+	r.mu.RLock()
+	var handlers []func(ChatboxRosterChange)
+	for _, sub := range r.subscribers {
+		handlers = append(handlers, sub.handler)
+	}
+	r.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ChatboxRosterChange{Type: change.Type, User: change.User.clone()})
+	}
+}
+
+// Internal function to copy the user, along with its linked Discord user and their roles.
+func (user ChatboxIngameUser) clone() ChatboxIngameUser {
+	if user.LinkedUser != nil {
+		linked := user.LinkedUser.clone()
+		user.LinkedUser = &linked
+	}
+
+	return user
+}
+
+// Internal function to copy the user, along with its roles and linked ingame user.
+func (user ChatboxDiscordUser) clone() ChatboxDiscordUser {
+	if user.Roles != nil {
+		roles := make([]*ChatboxDiscordRole, len(user.Roles))
+		for idx, role := range user.Roles {
+			if role != nil {
+				copied := *role
+				roles[idx] = &copied
+			}
+		}
+		user.Roles = roles
+	}
+
+	if user.LinkedUser != nil {
+		linked := user.LinkedUser.clone()
+		user.LinkedUser = &linked
+	}
+
+	return user
+}
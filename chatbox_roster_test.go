@@ -0,0 +1,79 @@
+package switchcraftgo
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestChatboxRoster(t *testing.T) {
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"players","time":"2024-05-29T15:16:28Z","players":[{"type":"ingame","name":"Erb3","uuid":"1","displayName":"erb"},{"type":"ingame","name":"Lemmmy","uuid":"2","world":"minecraft:overworld"}]}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"join","user":{"type":"ingame","name":"Alice","uuid":"3"}}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"leave","user":{"type":"ingame","name":"Erb3","uuid":"1"}}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"world_change","user":{"type":"ingame","name":"Lemmmy","uuid":"2"},"origin":"minecraft:overworld","destination":"minecraft:the_end"}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"afk","user":{"type":"ingame","name":"Alice","uuid":"3"}}`))
+	})
+
+	var changes []ChatboxRosterChangeType
+	sc.Roster().OnChange(func(change ChatboxRosterChange) {
+		changes = append(changes, change.Type)
+	})
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	sc.Listen()
+
+	roster := sc.Roster()
+	if roster.Len() != 2 {
+		t.Fatalf("expected 2 players online, got %v", roster.Players())
+	}
+
+	if _, ok := roster.Get("1"); ok {
+		t.Fatalf("player who left is still in the roster")
+	}
+
+	lemmmy, ok := roster.ByName("lemmmy")
+	if !ok || lemmmy.World != "minecraft:the_end" {
+		t.Fatalf("expected Lemmmy to be in the end, got %+v", lemmmy)
+	}
+
+	alice, ok := roster.Get("3")
+	if !ok || !alice.Afk {
+		t.Fatalf("expected Alice to be online and AFK, got %+v", alice)
+	}
+
+	if players := roster.Players(); players[0].Name != "Alice" || players[1].Name != "Lemmmy" {
+		t.Fatalf("expected players sorted by name, got %v", players)
+	}
+
+	expected := []ChatboxRosterChangeType{ChatboxRosterReset, ChatboxRosterJoin, ChatboxRosterLeave, ChatboxRosterUpdate, ChatboxRosterUpdate}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected changes %v, got %v", expected, changes)
+	}
+
+	for idx := range expected {
+		if changes[idx] != expected[idx] {
+			t.Fatalf("expected changes %v, got %v", expected, changes)
+		}
+	}
+}
+
+func TestChatboxRosterCopies(t *testing.T) {
+	linked := &ChatboxDiscordUser{Id: 1, Name: "erb", Roles: []*ChatboxDiscordRole{{Id: 2, Name: "Member"}}}
+	players := []ChatboxIngameUser{{Name: "Erb3", Uuid: "1", LinkedUser: linked}}
+
+	r := newChatboxRoster()
+	r.reset(players)
+	linked.Name = "changed"
+
+	user, _ := r.Get("1")
+	user.LinkedUser.Name = "changed"
+	user.LinkedUser.Roles[0].Name = "changed"
+
+	user, _ = r.ByName("erb3")
+	if user.LinkedUser.Name != "erb" || user.LinkedUser.Roles[0].Name != "Member" {
+		t.Fatalf("changing a returned player changed the roster, got %+v", *user.LinkedUser)
+	}
+}
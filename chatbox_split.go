@@ -0,0 +1,329 @@
+package switchcraftgo
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Max length of the text of a single tell or say, in characters.
+// Longer messages are split by [Chatbox.Tell] and [Chatbox.Say].
+const ChatboxMaxTextLength = 1024
+
+// Markdown delimiters which must not be split, longest first.
+var markdownDelimiters = []string{"```", "**", "__", "~~", "||", "`", "*", "_"}
+
+// Internal function to split a message into parts no longer than limit characters.
+// See [Chatbox.Say] for how the message is split.
+func splitMessage(message string, mode ChatboxFormattingMode, limit int) []string {
+	if utf8.RuneCountInString(message) <= limit {
+		return []string{message}
+	}
+
+	var parts []string
+	prefix := ""
+	rest := []rune(message)
+
+	for {
+		available := limit - utf8.RuneCountInString(prefix)
+		if len(rest) <= available {
+			return append(parts, prefix+string(rest))
+		}
+
+		cut, skip := findCut(rest, available, mode)
+		part := prefix + string(rest[:cut])
+		parts = append(parts, part)
+		rest = rest[cut+skip:]
+
+		switch mode {
+		case ChatboxFormattingFormat:
+			prefix = activeFormatCodes(part)
+		case ChatboxFormattingMiniMessage:
+			prefix = activeMiniMessageTags(part)
+		}
+
+		// Long chains of formatting would leave little room for text, so they are dropped instead.
+		if utf8.RuneCountInString(prefix) > limit/2 {
+			prefix = ""
+		}
+	}
+}
+
+// Internal function to find where to cut the text, so that the part before it is at most available characters.
+// Returns the index to cut at, and how many characters to drop after it, such as the space at a word break.
+func findCut(text []rune, available int, mode ChatboxFormattingMode) (int, int) {
+	protected := protectedRunes(text, mode)
+
+	for _, separator := range []rune{'\n', ' '} {
+		for idx := available; idx > 0; idx-- {
+			if text[idx] == separator && !protected[idx] {
+				return idx, 1
+			}
+		}
+	}
+
+	for idx := available; idx > 0; idx-- {
+		if !protected[idx] {
+			return idx, 0
+		}
+	}
+
+	// The span is longer than a whole part, so it has to be split. Only keep formatting codes whole.
+	if text[available-1] == '&' && available > 1 {
+		return available - 1, 0
+	}
+
+	return available, 0
+}
+
+// Internal function to find which indices the text must not be cut at,
+// because it would split a formatting code, a markdown span or a MiniMessage tag.
+func protectedRunes(text []rune, mode ChatboxFormattingMode) []bool {
+	protected := make([]bool, len(text)+1)
+
+	if mode == ChatboxFormattingFormat {
+		for idx := 0; idx+1 < len(text); idx++ {
+			if text[idx] == '&' && isFormatCode(text[idx+1]) {
+				protected[idx+1] = true
+			}
+		}
+
+		return protected
+	}
+
+	if mode == ChatboxFormattingMiniMessage {
+		for idx := 0; idx < len(text); idx++ {
+			if text[idx] == '\\' {
+				protected[idx+1] = true
+				idx++
+				continue
+			}
+
+			if end := miniMessageTagEnd(text, idx); end != -1 {
+				for inside := idx + 1; inside < end; inside++ {
+					protected[inside] = true
+				}
+				idx = end - 1
+			}
+		}
+
+		return protected
+	}
+
+	for idx := 0; idx < len(text); idx++ {
+		if text[idx] == '\\' {
+			protected[idx+1] = true
+			idx++
+			continue
+		}
+
+		end := -1
+		if text[idx] == '[' {
+			end = markdownLinkEnd(text, idx)
+		} else {
+			for _, delimiter := range markdownDelimiters {
+				if !hasRunePrefix(text[idx:], delimiter) {
+					continue
+				}
+
+				after := idx + len(delimiter)
+				if closing := indexRunes(text[after:], delimiter); closing != -1 {
+					end = after + closing + len(delimiter)
+				}
+				break
+			}
+		}
+
+		if end == -1 {
+			continue
+		}
+
+		for inside := idx + 1; inside < end; inside++ {
+			protected[inside] = true
+		}
+		idx = end - 1
+	}
+
+	return protected
+}
+
+// Reports whether the text starts with the ASCII prefix.
+func hasRunePrefix(text []rune, prefix string) bool {
+	if len(text) < len(prefix) {
+		return false
+	}
+
+	return string(text[:len(prefix)]) == prefix
+}
+
+// Returns the index of the first occurrence of the ASCII substring in the text, or -1.
+func indexRunes(text []rune, substr string) int {
+	for idx := 0; idx+len(substr) <= len(text); idx++ {
+		if string(text[idx:idx+len(substr)]) == substr {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+// Internal function to get the index after a markdown link starting at start, such as [text](url).
+// Returns -1 if it is not a link.
+func markdownLinkEnd(text []rune, start int) int {
+	closeBracket := -1
+	for idx := start + 1; idx < len(text); idx++ {
+		if text[idx] == ']' {
+			closeBracket = idx
+			break
+		}
+	}
+
+	if closeBracket == -1 || closeBracket+1 >= len(text) || text[closeBracket+1] != '(' {
+		return -1
+	}
+
+	for idx := closeBracket + 2; idx < len(text); idx++ {
+		if text[idx] == ')' {
+			return idx + 1
+		}
+	}
+
+	return -1
+}
+
+// Internal function to get the formatting codes active at the end of the text,
+// so that they can be carried over to the next part.
+func activeFormatCodes(text string) string {
+	var colour rune
+	var styles []rune
+
+	runes := []rune(text)
+	for idx := 0; idx+1 < len(runes); idx++ {
+		if runes[idx] != '&' || !isFormatCode(runes[idx+1]) {
+			continue
+		}
+
+		code := toLowerRune(runes[idx+1])
+		switch {
+		case code == 'r':
+			colour = 0
+			styles = nil
+		case code >= 'k' && code <= 'o':
+			if !strings.ContainsRune(string(styles), code) {
+				styles = append(styles, code)
+			}
+		default:
+			colour = code
+			styles = nil
+		}
+		idx++
+	}
+
+	var codes strings.Builder
+	if colour != 0 {
+		codes.WriteString("&" + string(colour))
+	}
+	for _, style := range styles {
+		codes.WriteString("&" + string(style))
+	}
+
+	return codes.String()
+}
+
+// Reports whether the character is a valid formatting code after an &.
+func isFormatCode(code rune) bool {
+	code = toLowerRune(code)
+	return (code >= '0' && code <= '9') || (code >= 'a' && code <= 'f') || (code >= 'k' && code <= 'o') || code == 'r'
+}
+
+func toLowerRune(r rune) rune {
+	if r >= 'A' && r <= 'Z' {
+		return r + 'a' - 'A'
+	}
+
+	return r
+}
+
+// MiniMessage tags which do not need closing, so are not carried over to the next part.
+var miniMessageVoidTags = map[string]bool{
+	"br": true, "newline": true, "reset": true, "key": true, "keybind": true, "lang": true, "translate": true, "tr": true,
+	"lang_or": true, "translate_or": true, "tr_or": true, "selector": true, "sel": true, "score": true, "nbt": true, "data": true,
+}
+
+// Internal function to get the index after a MiniMessage tag starting at start, such as <red> or <hover:show_text:'text'>.
+// Quoted arguments may contain > and other tags. Returns -1 if it is not a tag.
+func miniMessageTagEnd(text []rune, start int) int {
+	if text[start] != '<' {
+		return -1
+	}
+
+	var quote rune
+	for idx := start + 1; idx < len(text); idx++ {
+		r := text[idx]
+		switch {
+		case quote != 0 && r == '\\':
+			idx++
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '>':
+			if idx == start+1 {
+				return -1
+			}
+			return idx + 1
+		case r == '<' || r == '\n':
+			return -1
+		}
+	}
+
+	return -1
+}
+
+// Internal function to get the name of a MiniMessage tag from its contents, such as "hover" for hover:show_text:'text'.
+func miniMessageTagName(contents string) string {
+	name, _, _ := strings.Cut(contents, ":")
+	return strings.ToLower(name)
+}
+
+// Internal function to get the MiniMessage tags still open at the end of the text,
+// so that they can be carried over to the next part.
+func activeMiniMessageTags(text string) string {
+	var open []string
+
+	runes := []rune(text)
+	for idx := 0; idx < len(runes); idx++ {
+		if runes[idx] == '\\' {
+			idx++
+			continue
+		}
+
+		end := miniMessageTagEnd(runes, idx)
+		if end == -1 {
+			continue
+		}
+
+		tag := string(runes[idx:end])
+		contents := tag[1 : len(tag)-1]
+		idx = end - 1
+
+		switch name := miniMessageTagName(contents); {
+		case strings.HasPrefix(contents, "/"):
+			closing := miniMessageTagName(contents[1:])
+			for last := len(open) - 1; last >= 0; last-- {
+				if closing == "" || miniMessageTagName(open[last][1:len(open[last])-1]) == closing {
+					open = open[:last]
+					break
+				}
+			}
+		case name == "reset":
+			open = nil
+		case strings.HasSuffix(contents, "/") || miniMessageVoidTags[name]:
+		default:
+			open = append(open, tag)
+		}
+	}
+
+	return strings.Join(open, "")
+}
@@ -0,0 +1,109 @@
+package switchcraftgo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSplitMessageShort(t *testing.T) {
+	parts := splitMessage("hello world", ChatboxFormattingMarkdown, 20)
+	if len(parts) != 1 || parts[0] != "hello world" {
+		t.Fatalf("expected short message to be kept whole, got %q", parts)
+	}
+}
+
+func TestSplitMessageBoundaries(t *testing.T) {
+	parts := splitMessage("first line\nsecond line here", ChatboxFormattingMarkdown, 20)
+	if len(parts) != 2 || parts[0] != "first line" || parts[1] != "second line here" {
+		t.Fatalf("expected split at line break, got %q", parts)
+	}
+
+	parts = splitMessage("one two three four five", ChatboxFormattingMarkdown, 10)
+	expected := []string{"one two", "three four", "five"}
+	if strings.Join(parts, "|") != strings.Join(expected, "|") {
+		t.Fatalf("expected split between words %q, got %q", expected, parts)
+	}
+}
+
+func TestSplitMessageMarkdownSpans(t *testing.T) {
+	parts := splitMessage("hi **bold words** and [a link](https://sc3.io)", ChatboxFormattingMarkdown, 30)
+	for _, part := range parts {
+		if strings.Count(part, "**")%2 != 0 || strings.Count(part, "[") != strings.Count(part, ")") {
+			t.Fatalf("markdown span was split, got %q", parts)
+		}
+	}
+
+	if strings.Join(parts, " ") != "hi **bold words** and [a link](https://sc3.io)" {
+		t.Fatalf("message was changed while splitting, got %q", parts)
+	}
+}
+
+func TestSplitMessageFormatCodes(t *testing.T) {
+	parts := splitMessage("&c&lError: &6something went wrong", ChatboxFormattingFormat, 22)
+	if len(parts) != 2 || parts[0] != "&c&lError: &6something" || parts[1] != "&6went wrong" {
+		t.Fatalf("expected colour to be carried over, got %q", parts)
+	}
+
+	parts = splitMessage("aaaaaaaaa&cbbbbbbbbb", ChatboxFormattingFormat, 10)
+	if parts[0] != "aaaaaaaaa" || parts[1] != "&cbbbbbbbb" || parts[2] != "&cb" {
+		t.Fatalf("formatting code was split, got %q", parts)
+	}
+
+	if codes := activeFormatCodes("&aHi &lthere &rplain &bx&n"); codes != "&b&n" {
+		t.Fatalf("expected active codes &b&n, got %q", codes)
+	}
+}
+
+func TestSplitMessageMiniMessage(t *testing.T) {
+	parts := splitMessage("<red><hover:show_text:'a b c'>hover</hover> text here", ChatboxFormattingMiniMessage, 50)
+	if len(parts) != 2 || parts[0] != "<red><hover:show_text:'a b c'>hover</hover> text" || parts[1] != "<red>here" {
+		t.Fatalf("expected tag to be kept whole and colour carried over, got %q", parts)
+	}
+
+	if tags := activeMiniMessageTags("<red><bold>a</bold><br><click:run_command:'/x'>b \\<green>"); tags != "<red><click:run_command:'/x'>" {
+		t.Fatalf("expected active tags <red><click:run_command:'/x'>, got %q", tags)
+	}
+}
+
+func TestSplitMessageLimit(t *testing.T) {
+	message := strings.Repeat("&aword ", 500)
+	for _, part := range splitMessage(message, ChatboxFormattingFormat, ChatboxMaxTextLength) {
+		if len([]rune(part)) > ChatboxMaxTextLength {
+			t.Fatalf("part is longer than %d characters: %d", ChatboxMaxTextLength, len([]rune(part)))
+		}
+	}
+}
+
+func TestChatboxTellSplit(t *testing.T) {
+	received := make(chan string, 10)
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		for {
+			var packet ChatboxTellPacket
+			if err := conn.ReadJSON(&packet); err != nil {
+				return
+			}
+			received <- packet.Text
+			conn.WriteJSON(map[string]any{"ok": true, "type": "success", "id": packet.Id, "reason": "message_queued"})
+		}
+	})
+	sc.queue = newChatboxQueue(ChatboxQueueOptions{Rate: -1})
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	go sc.Listen()
+	defer sc.Close()
+
+	line := strings.Repeat("a", ChatboxMaxTextLength-1)
+	if _, err := sc.Tell("Erb3", line+"\n"+line+"\nend", "Test", ChatboxFormattingMarkdown).Wait(); err != nil {
+		t.Fatalf("Tell() of long message returned error %s", err.Error())
+	}
+
+	for _, expected := range []string{line, line, "end"} {
+		if text := <-received; text != expected {
+			t.Fatalf("expected part of %d characters, got %d", len(expected), len(text))
+		}
+	}
+}
@@ -0,0 +1,489 @@
+package switchcraftgo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Starts a local chatbox server that runs handler for every connection.
+// Returns a Chatbox pointed at the server.
+func newTestChatbox(t *testing.T, handler func(*websocket.Conn)) *Chatbox {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("failed to upgrade test connection: %s", err.Error())
+			return
+		}
+		defer conn.Close()
+
+		handler(conn)
+	}))
+	t.Cleanup(server.Close)
+
+	base, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse test server url: %s", err.Error())
+	}
+
+	return NewChatbox(NewChatboxOptions{
+		Token: "test",
+		Base: url.URL{
+			Scheme: "ws",
+			Host:   base.Host,
+			Path:   "/v2/",
+		},
+	})
+}
+
+func TestChatboxEventDispatch(t *testing.T) {
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"join","user":{"type":"ingame","name":"Erb3","uuid":"1234"},"time":"2024-05-29T15:16:28.042866052Z"}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"world_change","user":{"type":"ingame","name":"Erb3","uuid":"1234"},"origin":"minecraft:overworld","destination":"minecraft:the_nether","time":"2024-05-29T15:16:29Z"}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"afk_return","user":{"type":"ingame","name":"Erb3","uuid":"1234"},"time":"2024-05-29T15:16:30Z"}`))
+	})
+
+	var join ChatboxJoinPacket
+	var worldChange ChatboxWorldChangePacket
+	var afkReturn ChatboxAfkReturnPacket
+
+	sc.OnJoin = func(p ChatboxJoinPacket) { join = p }
+	sc.OnWorldChange = func(p ChatboxWorldChangePacket) { worldChange = p }
+	sc.OnAfkReturn = func(p ChatboxAfkReturnPacket) { afkReturn = p }
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	sc.Listen()
+
+	if join.User.Name != "Erb3" || join.Time.IsZero() {
+		t.Fatalf("join event was not decoded, got %+v", join)
+	}
+
+	if worldChange.Destination != "minecraft:the_nether" {
+		t.Fatalf("world_change event was not decoded, got %+v", worldChange)
+	}
+
+	if afkReturn.User.Uuid != "1234" {
+		t.Fatalf("afk_return event was not decoded, got %+v", afkReturn)
+	}
+}
+
+func TestChatboxChatDispatch(t *testing.T) {
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"chat_ingame","text":"hello","rawText":"&ahello","user":{"type":"ingame","name":"Erb3","uuid":"1234"},"time":"2022-10-22T20:50:45.6221607+01:00[Europe/London]"}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"chat_discord","text":"edited","discordId":"42","discordUser":{"type":"discord","id":1,"name":"erb3"},"edited":true,"time":"2024-05-29T15:16:28Z"}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"chat_chatbox","text":"hi","name":"Bot","rawName":"&aBot","user":{"type":"ingame","name":"Erb3","uuid":"1234"},"time":"2024-05-29T15:16:28Z"}`))
+	})
+
+	var ingame ChatboxIngameChatPacket
+	var discord ChatboxDiscordChatPacket
+	var chatbox ChatboxChatboxChatPacket
+
+	sc.OnChatIngame = func(p ChatboxIngameChatPacket) { ingame = p }
+	sc.OnChatDiscord = func(p ChatboxDiscordChatPacket) { discord = p }
+	sc.OnChatChatbox = func(p ChatboxChatboxChatPacket) { chatbox = p }
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	sc.Listen()
+
+	if ingame.RawText != "&ahello" || ingame.Time.Hour() != 20 {
+		t.Fatalf("chat_ingame event was not decoded, got %+v", ingame)
+	}
+
+	if !discord.Edited || discord.DiscordUser.Name != "erb3" {
+		t.Fatalf("chat_discord event was not decoded, got %+v", discord)
+	}
+
+	if chatbox.RawName != "&aBot" || chatbox.User.Name != "Erb3" {
+		t.Fatalf("chat_chatbox event was not decoded, got %+v", chatbox)
+	}
+}
+
+func TestChatboxTellResult(t *testing.T) {
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		var first, second ChatboxTellPacket
+		conn.ReadJSON(&first)
+		conn.ReadJSON(&second)
+
+		conn.WriteJSON(map[string]any{"ok": true, "type": "success", "id": first.Id, "reason": "message_queued"})
+		conn.WriteJSON(map[string]any{"ok": false, "type": "error", "id": second.Id, "error": "rate_limited", "message": "You are being rate limited"})
+	})
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	go sc.Listen()
+
+	first := sc.Tell("Erb3", "first", "Test", ChatboxFormattingMarkdown)
+	second := sc.Tell("Erb3", "second", "Test", ChatboxFormattingMarkdown)
+
+	if first.Id == second.Id {
+		t.Fatalf("Tell() returned the same id %d twice", first.Id)
+	}
+
+	reason, err := first.Wait()
+	if err != nil || reason != "message_queued" {
+		t.Fatalf("first Tell() completed with reason %q and error %v", reason, err)
+	}
+
+	_, err = second.Wait()
+	if !errors.Is(err, ErrChatboxRateLimited) {
+		t.Fatalf("second Tell() completed with error %v, expected rate_limited", err)
+	}
+}
+
+func TestChatboxSayResult(t *testing.T) {
+	received := make(chan ChatboxSayPacket, 1)
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		var packet ChatboxSayPacket
+		conn.ReadJSON(&packet)
+		received <- packet
+
+		conn.WriteJSON(map[string]any{"ok": true, "type": "success", "id": packet.Id, "reason": "message_queued"})
+		conn.ReadMessage()
+	})
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	go sc.Listen()
+	defer sc.Close()
+
+	result := sc.Say("&ahello", "Test", ChatboxFormattingFormat)
+
+	reason, err := result.Wait()
+	if err != nil || reason != "message_queued" {
+		t.Fatalf("Say() completed with reason %q and error %v", reason, err)
+	}
+
+	packet := <-received
+	if packet.Type != "say" || packet.Text != "&ahello" || packet.Name != "Test" || packet.Mode != "format" {
+		t.Fatalf("unexpected say packet %+v", packet)
+	}
+
+	if packet.Id == 0 || packet.Id != result.Id {
+		t.Fatalf("expected say packet id to be the result id %d, got %d", result.Id, packet.Id)
+	}
+}
+
+func TestChatboxCloseFailsPending(t *testing.T) {
+	received := make(chan struct{})
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		var packet ChatboxTellPacket
+		conn.ReadJSON(&packet)
+		close(received)
+		conn.ReadMessage()
+	})
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+
+	result := sc.Tell("Erb3", "hello", "Test", ChatboxFormattingMarkdown)
+	<-received
+
+	if err := sc.Close(); err != nil {
+		t.Fatalf("Close() returned error %s", err.Error())
+	}
+
+	select {
+	case <-result.Done():
+		if _, err := result.Wait(); err != ErrChatboxClosed {
+			t.Fatalf("expected ErrChatboxClosed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("result waiting for a response was not failed by Close()")
+	}
+}
+
+func TestChatboxReconnect(t *testing.T) {
+	var connections atomic.Int32
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		switch connections.Add(1) {
+		case 1:
+			conn.WriteJSON(map[string]any{"ok": true, "type": "closing", "closeReason": "server_stopping", "reason": "Server is stopping"})
+		default:
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"join","user":{"type":"ingame","name":"Erb3","uuid":"1234"}}`))
+			conn.WriteJSON(map[string]any{"ok": true, "type": "closing", "closeReason": "invalid_license_key", "reason": "Invalid license key"})
+		}
+	})
+	sc.reconnect = ChatboxReconnectOptions{Enabled: true, MinDelay: 10 * time.Millisecond, MaxAttempts: 3}
+
+	var reconnects int
+	var joined bool
+	sc.OnReconnect = func() { reconnects++ }
+	sc.OnJoin = func(p ChatboxJoinPacket) { joined = true }
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	sc.Listen()
+
+	if reconnects != 1 {
+		t.Fatalf("expected 1 reconnect, got %d", reconnects)
+	}
+
+	if !joined {
+		t.Fatalf("handlers were not kept after reconnecting")
+	}
+
+	if connections.Load() != 2 {
+		t.Fatalf("reconnected after fatal close reason, got %d connections", connections.Load())
+	}
+}
+
+func TestChatboxTellWhileReconnecting(t *testing.T) {
+	var connections atomic.Int32
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		if connections.Add(1) == 1 {
+			return
+		}
+
+		for {
+			var packet ChatboxTellPacket
+			if err := conn.ReadJSON(&packet); err != nil {
+				return
+			}
+			conn.WriteJSON(map[string]any{"ok": true, "type": "success", "id": packet.Id, "reason": "message_queued"})
+		}
+	})
+	sc.reconnect = ChatboxReconnectOptions{Enabled: true, MinDelay: 100 * time.Millisecond}
+
+	disconnected := make(chan *websocket.Conn, 2)
+	sc.OnDisconnect = func(_ error) { disconnected <- sc.getConn() }
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	go sc.Listen()
+	defer sc.Close()
+
+	if conn := <-disconnected; conn != nil {
+		t.Fatalf("expected Conn to be nil while reconnecting")
+	}
+
+	reason, err := sc.Tell("Erb3", "hello", "Test", ChatboxFormattingMarkdown).Wait()
+	if err != nil || reason != "message_queued" {
+		t.Fatalf("expected Tell() while reconnecting to be sent once reconnected, got reason %q and error %v", reason, err)
+	}
+
+	if connections.Load() != 2 {
+		t.Fatalf("expected 2 connections, got %d", connections.Load())
+	}
+}
+
+func TestChatboxRunCancel(t *testing.T) {
+	closeCode := make(chan int, 1)
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"join","user":{"type":"ingame","name":"Erb3","uuid":"1234"}}`))
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				var closeErr *websocket.CloseError
+				if errors.As(err, &closeErr) {
+					closeCode <- closeErr.Code
+				}
+				close(closeCode)
+				return
+			}
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sc.OnJoin = func(_ ChatboxJoinPacket) { cancel() }
+
+	done := make(chan error)
+	go func() { done <- sc.Run(ctx) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Run() returned %v, expected context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Run() did not return after the context was cancelled")
+	}
+
+	if code := <-closeCode; code != websocket.CloseNormalClosure {
+		t.Fatalf("expected close handshake with code %d, got %d", websocket.CloseNormalClosure, code)
+	}
+}
+
+func TestChatboxRunCloseReason(t *testing.T) {
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		conn.WriteJSON(map[string]any{"ok": true, "type": "closing", "closeReason": "invalid_license_key", "reason": "Invalid license key"})
+	})
+	sc.reconnect = ChatboxReconnectOptions{Enabled: true, MinDelay: 10 * time.Millisecond}
+
+	err := sc.Run(context.Background())
+
+	var closeErr *ChatboxCloseError
+	if !errors.As(err, &closeErr) || closeErr.CloseReason != "invalid_license_key" {
+		t.Fatalf("Run() returned %v, expected close error with invalid_license_key", err)
+	}
+}
+
+func TestChatboxDecodeErrors(t *testing.T) {
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(`not json`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"join","user":"not a user"}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"leave","user":{"type":"ingame","name":"Erb3","uuid":"1234","newField":1}}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"brand_new_event"}`))
+	})
+	sc.strict = true
+
+	var errs []error
+	var joins, leaves int
+	sc.OnError = func(err error) { errs = append(errs, err) }
+	sc.OnJoin = func(_ ChatboxJoinPacket) { joins++ }
+	sc.OnLeave = func(_ ChatboxLeavePacket) { leaves++ }
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+
+	if err := sc.Listen(); err == nil {
+		t.Fatalf("Listen() returned no error after the connection was dropped")
+	}
+
+	if len(errs) != 4 {
+		t.Fatalf("expected 4 decode errors, got %d: %v", len(errs), errs)
+	}
+
+	if joins != 0 {
+		t.Fatalf("malformed join event was passed to OnJoin")
+	}
+
+	if leaves != 1 {
+		t.Fatalf("leave event with unknown field was not passed to OnLeave in strict mode")
+	}
+
+	if !errors.Is(errs[3], ErrChatboxUnexpectedEvent) {
+		t.Fatalf("expected unexpected event error, got %v", errs[3])
+	}
+}
+
+// Run with -race to check that concurrent senders do not race on the connection.
+func TestChatboxConcurrentWrites(t *testing.T) {
+	const senders = 20
+	const messages = 10
+
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		for {
+			var packet ChatboxTellPacket
+			if err := conn.ReadJSON(&packet); err != nil {
+				return
+			}
+			conn.WriteJSON(map[string]any{"ok": true, "type": "success", "id": packet.Id, "reason": "message_queued"})
+		}
+	})
+	sc.queue = newChatboxQueue(ChatboxQueueOptions{Rate: -1})
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	go sc.Listen()
+	defer sc.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, senders*messages)
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < messages; j++ {
+				var result *ChatboxResult
+				if j%2 == 0 {
+					result = sc.Tell("Erb3", "hello", "Test", ChatboxFormattingMarkdown)
+				} else {
+					result = sc.Say("hello", "Test", ChatboxFormattingFormat)
+				}
+
+				if _, err := result.Wait(); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("concurrent send failed: %s", err.Error())
+	}
+}
+
+func TestChatboxSubscribers(t *testing.T) {
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		for i := 0; i < 2; i++ {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"command","user":{"type":"ingame","name":"Erb3","uuid":"1234"},"command":"other","args":[]}`))
+		}
+	})
+
+	var calls []string
+	sc.OnCommand = func(_ ChatboxCommandPacket) { calls = append(calls, "field") }
+	NewBrigadier(sc, "Test")
+
+	var unsubscribe func()
+	unsubscribe = sc.On(ChatboxEventCommand, func(ev ChatboxEvent) {
+		calls = append(calls, "first")
+		unsubscribe()
+	})
+	Subscribe(sc, func(p ChatboxCommandPacket) { calls = append(calls, "second:"+p.Command) })
+	sc.On(ChatboxEventAll, func(ev ChatboxEvent) { calls = append(calls, "all:"+ev.EventType()) })
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	sc.Listen()
+
+	expected := []string{"field", "first", "second:other", "all:command", "field", "second:other", "all:command"}
+	if len(calls) != len(expected) {
+		t.Fatalf("expected handler calls %v, got %v", expected, calls)
+	}
+
+	for idx := range expected {
+		if calls[idx] != expected[idx] {
+			t.Fatalf("expected handler calls %v, got %v", expected, calls)
+		}
+	}
+}
+
+func TestBrigadierError(t *testing.T) {
+	received := make(chan ChatboxTellPacket, 1)
+	sc := newTestChatbox(t, func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ok":true,"type":"event","event":"command","user":{"type":"ingame","name":"Erb3","uuid":"1234"},"command":"fail","args":[],"ownerOnly":false}`))
+
+		var packet ChatboxTellPacket
+		conn.ReadJSON(&packet)
+		received <- packet
+	})
+
+	b := NewBrigadier(sc, "Test")
+	b.Register(b.Literal("fail").Executes(func(ev *BrigadierInvocation) {
+		ev.Error("something went wrong")
+	}))
+
+	if err := sc.Connect(); err != nil {
+		t.Fatalf("Connect() returned error %s", err.Error())
+	}
+	sc.Listen()
+
+	packet := <-received
+	if packet.Text != "&c&lError: &csomething went wrong" || packet.User != "1234" || packet.Mode != "format" {
+		t.Fatalf("unexpected error reply %+v", packet)
+	}
+}
@@ -19,7 +19,7 @@ func main() {
 
 	root := switchcraftgo.NewBrigadier(cb, "Echo")
 	root.Register(root.Literal("echo").String("content").Executes(func(ev *switchcraftgo.BrigadierInvocation) {
-		ev.ReplyMarkdown(ev.ReadString("content"))
+		ev.SafeReplyMarkdown("%s", ev.ReadString("content"))
 	}))
 
 	cb.Connect()
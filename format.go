@@ -0,0 +1,267 @@
+package switchcraftgo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A colour code for [ChatboxFormattingFormat] mode.
+// Format mode only supports the 16 Minecraft colours.
+type ChatboxColour rune
+
+const (
+	ChatboxColourBlack       ChatboxColour = '0'
+	ChatboxColourDarkBlue    ChatboxColour = '1'
+	ChatboxColourDarkGreen   ChatboxColour = '2'
+	ChatboxColourDarkAqua    ChatboxColour = '3'
+	ChatboxColourDarkRed     ChatboxColour = '4'
+	ChatboxColourDarkPurple  ChatboxColour = '5'
+	ChatboxColourGold        ChatboxColour = '6'
+	ChatboxColourGray        ChatboxColour = '7'
+	ChatboxColourDarkGray    ChatboxColour = '8'
+	ChatboxColourBlue        ChatboxColour = '9'
+	ChatboxColourGreen       ChatboxColour = 'a'
+	ChatboxColourAqua        ChatboxColour = 'b'
+	ChatboxColourRed         ChatboxColour = 'c'
+	ChatboxColourLightPurple ChatboxColour = 'd'
+	ChatboxColourYellow      ChatboxColour = 'e'
+	ChatboxColourWhite       ChatboxColour = 'f'
+)
+
+// The RGB values of the colours, as shown in Minecraft.
+var chatboxColourRGB = map[ChatboxColour]uint32{
+	ChatboxColourBlack:       0x000000,
+	ChatboxColourDarkBlue:    0x0000AA,
+	ChatboxColourDarkGreen:   0x00AA00,
+	ChatboxColourDarkAqua:    0x00AAAA,
+	ChatboxColourDarkRed:     0xAA0000,
+	ChatboxColourDarkPurple:  0xAA00AA,
+	ChatboxColourGold:        0xFFAA00,
+	ChatboxColourGray:        0xAAAAAA,
+	ChatboxColourDarkGray:    0x555555,
+	ChatboxColourBlue:        0x5555FF,
+	ChatboxColourGreen:       0x55FF55,
+	ChatboxColourAqua:        0x55FFFF,
+	ChatboxColourRed:         0xFF5555,
+	ChatboxColourLightPurple: 0xFF55FF,
+	ChatboxColourYellow:      0xFFFF55,
+	ChatboxColourWhite:       0xFFFFFF,
+}
+
+// Returns the RGB value of the colour, as shown in Minecraft, such as 0xFF5555 for red.
+func (c ChatboxColour) RGB() uint32 {
+	return chatboxColourRGB[c]
+}
+
+// Returns the Minecraft colour closest to an RGB value, such as a Discord role colour.
+func NearestChatboxColour(rgb uint32) ChatboxColour {
+	nearest := ChatboxColourWhite
+	nearestDistance := -1
+
+	for _, code := range "0123456789abcdef" {
+		candidate := ChatboxColour(code)
+		distance := colourDistance(rgb, candidate.RGB())
+		if nearestDistance == -1 || distance < nearestDistance {
+			nearest = candidate
+			nearestDistance = distance
+		}
+	}
+
+	return nearest
+}
+
+// Internal function to get the squared distance between two RGB values.
+func colourDistance(a, b uint32) int {
+	distance := 0
+	for shift := 0; shift <= 16; shift += 8 {
+		diff := int(a>>shift&0xFF) - int(b>>shift&0xFF)
+		distance += diff * diff
+	}
+
+	return distance
+}
+
+// Builds a message for [ChatboxFormattingFormat] mode, without writing the codes by hand.
+// Must be made with [NewChatboxFormat].
+//
+// Like in Minecraft, setting a colour also clears bold, italic and the other styles.
+type ChatboxFormatBuilder struct {
+	builder strings.Builder
+}
+
+// Creates an empty [ChatboxFormatBuilder].
+func NewChatboxFormat() *ChatboxFormatBuilder {
+	return &ChatboxFormatBuilder{}
+}
+
+// Adds text. Any & codes in it are kept as they are.
+func (f *ChatboxFormatBuilder) Text(text string) *ChatboxFormatBuilder {
+	f.builder.WriteString(text)
+	return f
+}
+
+// Adds text escaped with [EscapeFormat], for text that should not be able to add formatting,
+// such as text supplied by users.
+func (f *ChatboxFormatBuilder) Escaped(text string) *ChatboxFormatBuilder {
+	return f.Text(EscapeFormat(text))
+}
+
+// Adds text formatted with [fmt.Sprintf].
+func (f *ChatboxFormatBuilder) Textf(format string, args ...any) *ChatboxFormatBuilder {
+	return f.Text(fmt.Sprintf(format, args...))
+}
+
+// Sets the colour of the following text, clearing any styles.
+func (f *ChatboxFormatBuilder) Colour(colour ChatboxColour) *ChatboxFormatBuilder {
+	return f.code(rune(colour))
+}
+
+// Makes the following text bold.
+func (f *ChatboxFormatBuilder) Bold() *ChatboxFormatBuilder {
+	return f.code('l')
+}
+
+// Makes the following text italic.
+func (f *ChatboxFormatBuilder) Italic() *ChatboxFormatBuilder {
+	return f.code('o')
+}
+
+// Underlines the following text.
+func (f *ChatboxFormatBuilder) Underline() *ChatboxFormatBuilder {
+	return f.code('n')
+}
+
+// Strikes through the following text.
+func (f *ChatboxFormatBuilder) Strikethrough() *ChatboxFormatBuilder {
+	return f.code('m')
+}
+
+// Makes the following text obfuscated, constantly changing random characters.
+func (f *ChatboxFormatBuilder) Obfuscated() *ChatboxFormatBuilder {
+	return f.code('k')
+}
+
+// Clears the colour and all styles.
+func (f *ChatboxFormatBuilder) Reset() *ChatboxFormatBuilder {
+	return f.code('r')
+}
+
+// Returns the built message.
+func (f *ChatboxFormatBuilder) String() string {
+	return f.builder.String()
+}
+
+func (f *ChatboxFormatBuilder) code(code rune) *ChatboxFormatBuilder {
+	f.builder.WriteRune('&')
+	f.builder.WriteRune(code)
+	return f
+}
+
+// Returned by [ValidateFormat] for a formatting problem in a message.
+// Index is the byte offset of the & starting the code.
+type ChatboxFormatError struct {
+	Index  int
+	Reason string
+}
+
+func (e *ChatboxFormatError) Error() string {
+	return fmt.Sprintf("invalid formatting at index %d: %s", e.Index, e.Reason)
+}
+
+// Checks a message for [ChatboxFormattingFormat] mode, before it is sent.
+// Reports an & at the end of the message, an & followed by a letter or digit which is not a code,
+// and codes at the end of the message, which have no text to format.
+// An & followed by anything else, such as a space, is plain text.
+// Returns a [*ChatboxFormatError] for the first problem found, or nil.
+func ValidateFormat(message string) error {
+	trailing := -1
+
+	for idx := 0; idx < len(message); idx++ {
+		if message[idx] != '&' {
+			if trailing != -1 && message[idx] != ' ' {
+				trailing = -1
+			}
+			continue
+		}
+
+		if idx+1 == len(message) {
+			return &ChatboxFormatError{Index: idx, Reason: "dangling & at end of message"}
+		}
+
+		next := rune(message[idx+1])
+		if !isFormatCode(next) {
+			if isAlphanumeric(next) {
+				return &ChatboxFormatError{Index: idx, Reason: fmt.Sprintf("unknown formatting code &%c", next)}
+			}
+			continue
+		}
+
+		if trailing == -1 {
+			trailing = idx
+		}
+		idx++
+	}
+
+	if trailing != -1 {
+		return &ChatboxFormatError{Index: trailing, Reason: "formatting codes at end of message do not format any text"}
+	}
+
+	return nil
+}
+
+func isAlphanumeric(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// Characters which have a meaning in chatbox markdown, and are escaped by [EscapeMarkdown].
+const markdownSpecialCharacters = "\\*_~`|[]()<>#"
+
+// Escapes text for [ChatboxFormattingMarkdown] mode, so that it is shown as is.
+// Use it for text supplied by users, so that they cannot add links, mentions or formatting.
+func EscapeMarkdown(text string) string {
+	var escaped strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune(markdownSpecialCharacters, r) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+
+	return escaped.String()
+}
+
+// Escapes text for [ChatboxFormattingFormat] mode, so that it is shown as is.
+// A zero width space is placed between any & and the code after it, so it is not read as a code.
+func EscapeFormat(text string) string {
+	runes := []rune(text)
+
+	var escaped strings.Builder
+	for idx, r := range runes {
+		escaped.WriteRune(r)
+		if r == '&' && idx+1 < len(runes) && isFormatCode(runes[idx+1]) {
+			escaped.WriteRune('\u200b')
+		}
+	}
+
+	return escaped.String()
+}
+
+// Internal argument wrapper for [fmt.Sprintf], which escapes the formatted argument.
+type escapedArg struct {
+	value  any
+	escape func(string) string
+}
+
+func (a escapedArg) Format(state fmt.State, verb rune) {
+	fmt.Fprint(state, a.escape(fmt.Sprintf(fmt.FormatString(state, verb), a.value)))
+}
+
+// Internal function to format a template with [fmt.Sprintf], escaping every argument but not the template.
+func sprintfEscaped(escape func(string) string, template string, args ...any) string {
+	wrapped := make([]any, len(args))
+	for idx, arg := range args {
+		wrapped[idx] = escapedArg{value: arg, escape: escape}
+	}
+
+	return fmt.Sprintf(template, wrapped...)
+}
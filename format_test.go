@@ -0,0 +1,88 @@
+package switchcraftgo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestChatboxFormatBuilder(t *testing.T) {
+	message := NewChatboxFormat().
+		Colour(ChatboxColourRed).Bold().Text("Error: ").
+		Colour(ChatboxColourRed).Textf("%d things went wrong", 3).
+		Reset().Italic().Underline().Strikethrough().Obfuscated().Text("!").
+		String()
+
+	expected := "&c&lError: &c3 things went wrong&r&o&n&m&k!"
+	if message != expected {
+		t.Fatalf("expected %q, got %q", expected, message)
+	}
+
+	if err := ValidateFormat(message); err != nil {
+		t.Fatalf("ValidateFormat() rejected built message: %s", err.Error())
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	valid := []string{"plain text", "&aGreen &lbold", "Tom & Jerry", "&6&lGold"}
+	for _, message := range valid {
+		if err := ValidateFormat(message); err != nil {
+			t.Fatalf("ValidateFormat(%q) returned error %s", message, err.Error())
+		}
+	}
+
+	invalid := map[string]int{
+		"dangling &":      9,
+		"&zunknown":       0,
+		"text then &a&l ": 10,
+	}
+	for message, index := range invalid {
+		var formatErr *ChatboxFormatError
+		if err := ValidateFormat(message); !errors.As(err, &formatErr) || formatErr.Index != index {
+			t.Fatalf("ValidateFormat(%q) returned %v, expected error at index %d", message, err, index)
+		}
+	}
+}
+
+func ExampleNewChatboxFormat() {
+	message := NewChatboxFormat().
+		Colour(ChatboxColourGold).Bold().Text("Welcome! ").
+		Colour(ChatboxColourGray).Text("Type \\help to get started.").
+		String()
+
+	fmt.Println(message)
+	// Output: &6&lWelcome! &7Type \help to get started.
+}
+
+func TestEscapeMarkdown(t *testing.T) {
+	escaped := EscapeMarkdown("**bold** [link](https://example.com) <@123> `code`")
+	expected := "\\*\\*bold\\*\\* \\[link\\]\\(https://example.com\\) \\<@123\\> \\`code\\`"
+	if escaped != expected {
+		t.Fatalf("expected %q, got %q", expected, escaped)
+	}
+}
+
+func TestEscapeFormat(t *testing.T) {
+	escaped := EscapeFormat("&cred & Tom &&l")
+	expected := "&\u200bcred & Tom &&\u200bl"
+	if escaped != expected {
+		t.Fatalf("expected %q, got %q", expected, escaped)
+	}
+
+	if err := ValidateFormat(escaped); err != nil {
+		t.Fatalf("ValidateFormat() rejected escaped message: %s", err.Error())
+	}
+
+	built := NewChatboxFormat().Colour(ChatboxColourGreen).Escaped("&kx").String()
+	if built != "&a&\u200bkx" {
+		t.Fatalf("unexpected escaped builder output %q", built)
+	}
+}
+
+func TestSprintfEscaped(t *testing.T) {
+	message := sprintfEscaped(EscapeMarkdown, "**%s** has %d items: %q", "_user_", 3, "[x]")
+	expected := "**\\_user\\_** has 3 items: \"\\[x\\]\""
+	if message != expected {
+		t.Fatalf("expected %q, got %q", expected, message)
+	}
+}
@@ -0,0 +1,180 @@
+package switchcraftgo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The MiniMessage names of the Minecraft colours.
+var miniMessageColourNames = map[ChatboxColour]string{
+	ChatboxColourBlack:       "black",
+	ChatboxColourDarkBlue:    "dark_blue",
+	ChatboxColourDarkGreen:   "dark_green",
+	ChatboxColourDarkAqua:    "dark_aqua",
+	ChatboxColourDarkRed:     "dark_red",
+	ChatboxColourDarkPurple:  "dark_purple",
+	ChatboxColourGold:        "gold",
+	ChatboxColourGray:        "gray",
+	ChatboxColourDarkGray:    "dark_gray",
+	ChatboxColourBlue:        "blue",
+	ChatboxColourGreen:       "green",
+	ChatboxColourAqua:        "aqua",
+	ChatboxColourRed:         "red",
+	ChatboxColourLightPurple: "light_purple",
+	ChatboxColourYellow:      "yellow",
+	ChatboxColourWhite:       "white",
+}
+
+// Builds a message for [ChatboxFormattingMiniMessage] mode, without writing the tags by hand.
+// Must be made with [NewChatboxMiniMessage].
+//
+// Every tag applies to the text after it, until it is closed with [ChatboxMiniMessageBuilder.Close].
+// Tags which are still open at the end of the message do not need closing.
+type ChatboxMiniMessageBuilder struct {
+	builder strings.Builder
+	open    []string
+}
+
+// Creates an empty [ChatboxMiniMessageBuilder].
+func NewChatboxMiniMessage() *ChatboxMiniMessageBuilder {
+	return &ChatboxMiniMessageBuilder{}
+}
+
+// Adds text. Any tags in it are kept as they are.
+func (m *ChatboxMiniMessageBuilder) Text(text string) *ChatboxMiniMessageBuilder {
+	m.builder.WriteString(text)
+	return m
+}
+
+// Adds text escaped with [EscapeMiniMessage], for text that should not be able to add tags,
+// such as text supplied by users.
+func (m *ChatboxMiniMessageBuilder) Escaped(text string) *ChatboxMiniMessageBuilder {
+	return m.Text(EscapeMiniMessage(text))
+}
+
+// Adds text formatted with [fmt.Sprintf].
+func (m *ChatboxMiniMessageBuilder) Textf(format string, args ...any) *ChatboxMiniMessageBuilder {
+	return m.Text(fmt.Sprintf(format, args...))
+}
+
+// Opens one of the 16 Minecraft colours.
+func (m *ChatboxMiniMessageBuilder) Colour(colour ChatboxColour) *ChatboxMiniMessageBuilder {
+	return m.tag(miniMessageColourNames[colour])
+}
+
+// Opens a hex colour, such as 0xFF5555.
+func (m *ChatboxMiniMessageBuilder) HexColour(rgb uint32) *ChatboxMiniMessageBuilder {
+	return m.tag("color", hexColour(rgb))
+}
+
+// Opens a gradient between the hex colours, such as 0xFF5555 and 0x5555FF.
+func (m *ChatboxMiniMessageBuilder) Gradient(colours ...uint32) *ChatboxMiniMessageBuilder {
+	args := make([]string, len(colours))
+	for idx, colour := range colours {
+		args[idx] = hexColour(colour)
+	}
+
+	return m.tag("gradient", args...)
+}
+
+// Opens a rainbow.
+func (m *ChatboxMiniMessageBuilder) Rainbow() *ChatboxMiniMessageBuilder {
+	return m.tag("rainbow")
+}
+
+// Opens bold text.
+func (m *ChatboxMiniMessageBuilder) Bold() *ChatboxMiniMessageBuilder {
+	return m.tag("bold")
+}
+
+// Opens italic text.
+func (m *ChatboxMiniMessageBuilder) Italic() *ChatboxMiniMessageBuilder {
+	return m.tag("italic")
+}
+
+// Opens underlined text.
+func (m *ChatboxMiniMessageBuilder) Underline() *ChatboxMiniMessageBuilder {
+	return m.tag("underlined")
+}
+
+// Opens struck through text.
+func (m *ChatboxMiniMessageBuilder) Strikethrough() *ChatboxMiniMessageBuilder {
+	return m.tag("strikethrough")
+}
+
+// Opens obfuscated text, which constantly changes.
+func (m *ChatboxMiniMessageBuilder) Obfuscated() *ChatboxMiniMessageBuilder {
+	return m.tag("obfuscated")
+}
+
+// Opens text which shows the hover text when the mouse is over it.
+// The hover text is MiniMessage too, and can be made with another builder.
+func (m *ChatboxMiniMessageBuilder) Hover(text string) *ChatboxMiniMessageBuilder {
+	return m.tag("hover", "show_text", quoteMiniMessage(text))
+}
+
+// Opens text which runs the command when clicked, such as "/help".
+func (m *ChatboxMiniMessageBuilder) ClickRunCommand(command string) *ChatboxMiniMessageBuilder {
+	return m.tag("click", "run_command", quoteMiniMessage(command))
+}
+
+// Opens text which puts the command in the chat box when clicked, so the user can change it before running it.
+func (m *ChatboxMiniMessageBuilder) ClickSuggestCommand(command string) *ChatboxMiniMessageBuilder {
+	return m.tag("click", "suggest_command", quoteMiniMessage(command))
+}
+
+// Opens text which opens the URL when clicked.
+func (m *ChatboxMiniMessageBuilder) ClickOpenURL(url string) *ChatboxMiniMessageBuilder {
+	return m.tag("click", "open_url", quoteMiniMessage(url))
+}
+
+// Opens text which copies the text to the clipboard when clicked.
+func (m *ChatboxMiniMessageBuilder) ClickCopy(text string) *ChatboxMiniMessageBuilder {
+	return m.tag("click", "copy_to_clipboard", quoteMiniMessage(text))
+}
+
+// Closes the last opened tag. Does nothing if there are no open tags.
+func (m *ChatboxMiniMessageBuilder) Close() *ChatboxMiniMessageBuilder {
+	if len(m.open) == 0 {
+		return m
+	}
+
+	m.builder.WriteString("</" + m.open[len(m.open)-1] + ">")
+	m.open = m.open[:len(m.open)-1]
+	return m
+}
+
+// Closes every open tag.
+func (m *ChatboxMiniMessageBuilder) Reset() *ChatboxMiniMessageBuilder {
+	m.builder.WriteString("<reset>")
+	m.open = nil
+	return m
+}
+
+// Returns the built message.
+func (m *ChatboxMiniMessageBuilder) String() string {
+	return m.builder.String()
+}
+
+// Internal function to open a tag with arguments.
+func (m *ChatboxMiniMessageBuilder) tag(name string, args ...string) *ChatboxMiniMessageBuilder {
+	m.builder.WriteString("<" + strings.Join(append([]string{name}, args...), ":") + ">")
+	m.open = append(m.open, name)
+	return m
+}
+
+// Internal function to format a colour for MiniMessage, such as #ff5555.
+func hexColour(rgb uint32) string {
+	return fmt.Sprintf("#%06x", rgb&0xFFFFFF)
+}
+
+// Internal function to quote a tag argument, so it can contain colons and tags.
+func quoteMiniMessage(text string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(text) + "'"
+}
+
+// Escapes text for [ChatboxFormattingMiniMessage] mode, so that it is shown as is.
+// Use it for text supplied by users, so that they cannot add tags such as click actions.
+func EscapeMiniMessage(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `<`, `\<`).Replace(text)
+}
@@ -0,0 +1,55 @@
+package switchcraftgo
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestChatboxMiniMessageBuilder(t *testing.T) {
+	message := NewChatboxMiniMessage().
+		Colour(ChatboxColourGold).Bold().Text("Shop").Close().Close().
+		Text(" ").
+		Hover("Buy <green>it's cheap").ClickRunCommand("/buy diamond").Gradient(0xFF5555, 0x5555FF).Escaped("<buy>").Close().Close().Close().
+		HexColour(0x00AA00).Underline().Text("done").
+		String()
+
+	expected := "<gold><bold>Shop</bold></gold> " +
+		"<hover:show_text:'Buy <green>it\\'s cheap'><click:run_command:'/buy diamond'><gradient:#ff5555:#5555ff>\\<buy></gradient></click></hover>" +
+		"<color:#00aa00><underlined>done"
+	if message != expected {
+		t.Fatalf("expected %q, got %q", expected, message)
+	}
+}
+
+func TestEscapeMiniMessage(t *testing.T) {
+	escaped := EscapeMiniMessage(`<click:run_command:'/kill'>\<b>`)
+	expected := `\<click:run_command:'/kill'>\\\<b>`
+	if escaped != expected {
+		t.Fatalf("expected %q, got %q", expected, escaped)
+	}
+}
+
+func TestChatboxFormattingModeString(t *testing.T) {
+	modes := map[ChatboxFormattingMode]string{
+		ChatboxFormattingMarkdown:    "markdown",
+		ChatboxFormattingFormat:      "format",
+		ChatboxFormattingMiniMessage: "minimessage",
+		ChatboxFormattingMode(7):     "ChatboxFormattingMode(7)",
+	}
+	for mode, expected := range modes {
+		if mode.String() != expected {
+			t.Fatalf("expected %q, got %q", expected, mode.String())
+		}
+	}
+}
+
+func ExampleNewChatboxMiniMessage() {
+	message := NewChatboxMiniMessage().
+		Text("Type ").
+		Hover("Click to run").ClickRunCommand("\\help").Colour(ChatboxColourAqua).Text("\\help").Close().Close().Close().
+		Text(" for help").
+		String()
+
+	fmt.Println(message)
+	// Output: Type <hover:show_text:'Click to run'><click:run_command:'\\help'><aqua>\help</aqua></click></hover> for help
+}
@@ -0,0 +1,224 @@
+package render
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// Parses a message using & formatting codes, as sent in format mode, into a tree of spans.
+// The § section sign used by Minecraft is also read as a code.
+// Like in Minecraft, a colour code clears the styles set before it, and &r clears everything.
+// An & which does not start a valid code is kept as text.
+func ParseFormat(message string) *Span {
+	root := &Span{}
+	runes := []rune(message)
+
+	var style Style
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			root.Children = append(root.Children, &Span{Style: style, Text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for idx := 0; idx < len(runes); idx++ {
+		r := runes[idx]
+		if (r != '&' && r != '§') || idx+1 == len(runes) {
+			text.WriteRune(r)
+			continue
+		}
+
+		code := unicode.ToLower(runes[idx+1])
+		next := style
+		switch {
+		case colours[Colour(code)] != colourInfo{}:
+			next = Style{Colour: Colour(code)}
+		case code == 'k':
+			next.Obfuscated = true
+		case code == 'l':
+			next.Bold = true
+		case code == 'm':
+			next.Strikethrough = true
+		case code == 'n':
+			next.Underline = true
+		case code == 'o':
+			next.Italic = true
+		case code == 'r':
+			next = Style{}
+		default:
+			text.WriteRune(r)
+			continue
+		}
+
+		flush()
+		style = next
+		idx++
+	}
+	flush()
+
+	return root
+}
+
+// The markdown delimiters supported by the chatbox, longest first, and the style they apply.
+var markdownDelimiters = []struct {
+	delimiter string
+	style     Style
+}{
+	{"**", Style{Bold: true}},
+	{"__", Style{Underline: true}},
+	{"~~", Style{Strikethrough: true}},
+	{"||", Style{Spoiler: true}},
+	{"*", Style{Italic: true}},
+	{"_", Style{Italic: true}},
+}
+
+// Parses a message using the markdown subset of the chatbox into a tree of spans.
+// Supports **bold**, *italic* or _italic_, __underline__, ~~strikethrough~~, ||spoilers||,
+// `code`, [links](https://example.com) and backslash escapes.
+// Only http and https links are read as links, so that links such as javascript: are kept as text.
+// Delimiters without a matching closing delimiter are kept as text.
+func ParseMarkdown(message string) *Span {
+	parser := &markdownParser{runes: []rune(message)}
+	children, _ := parser.parse("")
+	return &Span{Children: children}
+}
+
+type markdownParser struct {
+	runes []rune
+	pos   int
+	// Delimiters which were not closed, by the position they start at, so they are only parsed once.
+	unclosed map[int]string
+}
+
+// Internal function to parse spans until the closing delimiter, or the end of the message if it is empty.
+// Returns false if the closing delimiter was not found.
+func (p *markdownParser) parse(closer string) ([]*Span, bool) {
+	var spans []*Span
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			spans = append(spans, &Span{Text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for p.pos < len(p.runes) {
+		if closer != "" && p.hasPrefix(closer) {
+			p.pos += len(closer)
+			flush()
+			return spans, true
+		}
+
+		r := p.runes[p.pos]
+		if r == '\\' && p.pos+1 < len(p.runes) && isEscapable(p.runes[p.pos+1]) {
+			text.WriteRune(p.runes[p.pos+1])
+			p.pos += 2
+			continue
+		}
+
+		if r == '`' {
+			if end := p.index("`", p.pos+1); end != -1 {
+				flush()
+				spans = append(spans, &Span{Style: Style{Code: true}, Text: string(p.runes[p.pos+1 : end])})
+				p.pos = end + 1
+				continue
+			}
+		}
+
+		if r == '[' {
+			if link := p.link(); link != nil {
+				flush()
+				spans = append(spans, link)
+				continue
+			}
+		}
+
+		if delimiter, style, ok := p.delimiter(); ok {
+			start := p.pos
+			p.pos += len(delimiter)
+			if p.unclosed[start] != delimiter {
+				if children, closed := p.parse(delimiter); closed && len(children) > 0 {
+					flush()
+					spans = append(spans, &Span{Style: style, Children: children})
+					continue
+				}
+
+				if p.unclosed == nil {
+					p.unclosed = map[int]string{}
+				}
+				p.unclosed[start] = delimiter
+				p.pos = start + len(delimiter)
+			}
+
+			text.WriteString(delimiter)
+			continue
+		}
+
+		text.WriteRune(r)
+		p.pos++
+	}
+
+	flush()
+	return spans, closer == ""
+}
+
+// Internal function to parse a [label](url) link at the current position.
+// Returns nil, without moving, if there is no valid link, or it is not an http or https link.
+func (p *markdownParser) link() *Span {
+	labelEnd := p.index("](", p.pos+1)
+	if labelEnd == -1 {
+		return nil
+	}
+	urlEnd := p.index(")", labelEnd+2)
+	if urlEnd == -1 {
+		return nil
+	}
+
+	target := string(p.runes[labelEnd+2 : urlEnd])
+	if target == "" || strings.ContainsFunc(target, unicode.IsSpace) {
+		return nil
+	}
+
+	parsed, err := url.Parse(target)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil
+	}
+
+	label := &markdownParser{runes: p.runes[p.pos+1 : labelEnd]}
+	children, _ := label.parse("")
+	p.pos = urlEnd + 1
+	return &Span{Style: Style{Link: target}, Children: children}
+}
+
+// Internal function to find the delimiter starting at the current position.
+func (p *markdownParser) delimiter() (string, Style, bool) {
+	for _, d := range markdownDelimiters {
+		if p.hasPrefix(d.delimiter) {
+			return d.delimiter, d.style, true
+		}
+	}
+	return "", Style{}, false
+}
+
+func (p *markdownParser) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(string(p.runes[p.pos:min(p.pos+len(prefix), len(p.runes))]), prefix)
+}
+
+// Internal function to find the rune index of substr, starting from the given rune index.
+func (p *markdownParser) index(substr string, from int) int {
+	if from > len(p.runes) {
+		return -1
+	}
+	idx := strings.Index(string(p.runes[from:]), substr)
+	if idx == -1 {
+		return -1
+	}
+	return from + len([]rune(string(p.runes[from:])[:idx]))
+}
+
+// Internal function to check whether a rune can be escaped with a backslash.
+func isEscapable(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
@@ -0,0 +1,234 @@
+// Package render converts chatbox formatting into other formats, for logging and mirroring chat.
+//
+// Messages are first parsed into a tree of [Span]s, with [ParseFormat] for & formatting codes,
+// or [ParseMarkdown] for the markdown subset supported by the chatbox.
+// The tree can then be written as ANSI coloured terminal text, plain text or HTML.
+package render
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// A Minecraft colour, as its formatting code from '0' to 'f'. Zero means no colour.
+type Colour rune
+
+// The style of a [Span]. Styles are inherited by the children of a span,
+// with the child's colour and link replacing the parent's when set.
+type Style struct {
+	Colour        Colour
+	Bold          bool
+	Italic        bool
+	Underline     bool
+	Strikethrough bool
+	Obfuscated    bool
+	Code          bool
+	Spoiler       bool
+	Link          string
+}
+
+// A part of a message. A span has either text, or children, which are shown in order.
+type Span struct {
+	Style    Style
+	Text     string
+	Children []*Span
+}
+
+type colourInfo struct {
+	ansi int
+	hex  string
+}
+
+// The ANSI foreground codes and hex values of the Minecraft colours.
+var colours = map[Colour]colourInfo{
+	'0': {30, "#000000"},
+	'1': {34, "#0000AA"},
+	'2': {32, "#00AA00"},
+	'3': {36, "#00AAAA"},
+	'4': {31, "#AA0000"},
+	'5': {35, "#AA00AA"},
+	'6': {33, "#FFAA00"},
+	'7': {37, "#AAAAAA"},
+	'8': {90, "#555555"},
+	'9': {94, "#5555FF"},
+	'a': {92, "#55FF55"},
+	'b': {96, "#55FFFF"},
+	'c': {91, "#FF5555"},
+	'd': {95, "#FF55FF"},
+	'e': {93, "#FFFF55"},
+	'f': {97, "#FFFFFF"},
+}
+
+// Returns the hex value of the colour, such as "#FF5555", or an empty string if it is not a colour.
+func (c Colour) Hex() string {
+	return colours[c].hex
+}
+
+// Internal function to apply a child's style on top of its parent's.
+func (s Style) inherit(child Style) Style {
+	if child.Colour != 0 {
+		s.Colour = child.Colour
+	}
+	if child.Link != "" {
+		s.Link = child.Link
+	}
+	s.Bold = s.Bold || child.Bold
+	s.Italic = s.Italic || child.Italic
+	s.Underline = s.Underline || child.Underline
+	s.Strikethrough = s.Strikethrough || child.Strikethrough
+	s.Obfuscated = s.Obfuscated || child.Obfuscated
+	s.Code = s.Code || child.Code
+	s.Spoiler = s.Spoiler || child.Spoiler
+	return s
+}
+
+// A piece of text with its full style, after inheritance.
+type run struct {
+	style Style
+	text  string
+}
+
+// Internal function to flatten the tree into runs of text, in order.
+func (s *Span) runs(parent Style, out []run) []run {
+	style := parent.inherit(s.Style)
+	if s.Text != "" {
+		out = append(out, run{style: style, text: s.Text})
+	}
+	for _, child := range s.Children {
+		out = child.runs(style, out)
+	}
+	return out
+}
+
+// Returns the text of the span and its children, without any formatting.
+func (s *Span) PlainText() string {
+	var builder strings.Builder
+	for _, r := range s.runs(Style{}, nil) {
+		builder.WriteString(r.text)
+	}
+	return builder.String()
+}
+
+// Returns the span as text for a terminal, using ANSI escape codes for colours and styles.
+// Links are written as OSC 8 hyperlinks, and spoilers are shown in reverse video.
+// Control characters in the text and links are removed, other than line breaks and tabs in the text,
+// so that messages cannot send their own escape codes to the terminal.
+// The output always ends with the styles reset.
+func (s *Span) ANSI() string {
+	var builder strings.Builder
+	for _, r := range s.runs(Style{}, nil) {
+		codes := []string{"0"}
+		if info, ok := colours[r.style.Colour]; ok {
+			codes = append(codes, fmt.Sprint(info.ansi))
+		}
+		if r.style.Bold {
+			codes = append(codes, "1")
+		}
+		if r.style.Italic {
+			codes = append(codes, "3")
+		}
+		if r.style.Underline || r.style.Link != "" {
+			codes = append(codes, "4")
+		}
+		if r.style.Obfuscated {
+			codes = append(codes, "5")
+		}
+		if r.style.Spoiler {
+			codes = append(codes, "7")
+		}
+		if r.style.Strikethrough {
+			codes = append(codes, "9")
+		}
+		if r.style.Code {
+			codes = append(codes, "2")
+		}
+
+		text := stripControl(r.text, true)
+		builder.WriteString("\x1b[" + strings.Join(codes, ";") + "m")
+		if link := stripControl(r.style.Link, false); link != "" {
+			builder.WriteString("\x1b]8;;" + link + "\x1b\\" + text + "\x1b]8;;\x1b\\")
+		} else {
+			builder.WriteString(text)
+		}
+	}
+	builder.WriteString("\x1b[0m")
+	return builder.String()
+}
+
+// Internal function to remove C0 and C1 control characters, such as ESC and BEL, from text for a terminal.
+// Line breaks and tabs are kept if keepWhitespace is set.
+func stripControl(text string, keepWhitespace bool) string {
+	return strings.Map(func(r rune) rune {
+		if keepWhitespace && (r == '\n' || r == '\t') {
+			return r
+		}
+		if r < 0x20 || (r >= 0x7f && r <= 0x9f) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// Returns the span as HTML, with all text escaped.
+// Colours and styles are inline styles, code is a <code> element, links are <a> elements
+// and spoilers are <span class="spoiler"> elements, which need styling by the page.
+func (s *Span) HTML() string {
+	var builder strings.Builder
+	s.writeHTML(&builder)
+	return builder.String()
+}
+
+func (s *Span) writeHTML(builder *strings.Builder) {
+	var closers []string
+	open := func(tag, closer string) {
+		builder.WriteString(tag)
+		closers = append(closers, closer)
+	}
+
+	if s.Style.Link != "" {
+		open(`<a href="`+html.EscapeString(s.Style.Link)+`">`, "</a>")
+	}
+	if s.Style.Spoiler {
+		open(`<span class="spoiler">`, "</span>")
+	}
+	if s.Style.Code {
+		open("<code>", "</code>")
+	}
+
+	var css []string
+	if hex := s.Style.Colour.Hex(); hex != "" {
+		css = append(css, "color:"+hex)
+	}
+	if s.Style.Bold {
+		css = append(css, "font-weight:bold")
+	}
+	if s.Style.Italic {
+		css = append(css, "font-style:italic")
+	}
+	var decorations []string
+	if s.Style.Underline {
+		decorations = append(decorations, "underline")
+	}
+	if s.Style.Strikethrough {
+		decorations = append(decorations, "line-through")
+	}
+	if len(decorations) > 0 {
+		css = append(css, "text-decoration:"+strings.Join(decorations, " "))
+	}
+	if s.Style.Obfuscated {
+		css = append(css, "filter:blur(3px)")
+	}
+	if len(css) > 0 {
+		open(`<span style="`+strings.Join(css, ";")+`">`, "</span>")
+	}
+
+	builder.WriteString(html.EscapeString(s.Text))
+	for _, child := range s.Children {
+		child.writeHTML(builder)
+	}
+
+	for idx := len(closers) - 1; idx >= 0; idx-- {
+		builder.WriteString(closers[idx])
+	}
+}
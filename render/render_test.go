@@ -0,0 +1,137 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	span := ParseFormat("&c&lError: &cbad §ainput&r & done")
+
+	expected := []Span{
+		{Style: Style{Colour: 'c', Bold: true}, Text: "Error: "},
+		{Style: Style{Colour: 'c'}, Text: "bad "},
+		{Style: Style{Colour: 'a'}, Text: "input"},
+		{Text: " & done"},
+	}
+	if len(span.Children) != len(expected) {
+		t.Fatalf("expected %d spans, got %d", len(expected), len(span.Children))
+	}
+	for idx, child := range span.Children {
+		if child.Style != expected[idx].Style || child.Text != expected[idx].Text {
+			t.Fatalf("span %d: expected %+v, got %+v", idx, expected[idx], *child)
+		}
+	}
+
+	if text := span.PlainText(); text != "Error: bad input & done" {
+		t.Fatalf("unexpected plain text %q", text)
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	cases := map[string]string{
+		"**bold** and *it* _it_":           "[b:bold] and [i:it] [i:it]",
+		"__under ~~strike~~__":             "[u:under [s:strike]]",
+		"||secret|| `**code**`":            "[spoiler:secret] [code:**code**]",
+		"[**site**](https://example.com)!": "[link https://example.com:[b:site]]!",
+		"\\*not italic\\* 2 * 3":           "*not italic* 2 * 3",
+		"**unclosed *italic*":              "**unclosed [i:italic]",
+		"[not a link](with space)":         "[not a link](with space)",
+	}
+
+	for message, expected := range cases {
+		if tree := describe(ParseMarkdown(message)); tree != expected {
+			t.Errorf("ParseMarkdown(%q): expected %q, got %q", message, expected, tree)
+		}
+	}
+}
+
+func TestParseMarkdownUnclosed(t *testing.T) {
+	message := strings.Repeat("**a_~|", 300) + "*end"
+	if text := ParseMarkdown(message).PlainText(); !strings.HasSuffix(text, "*end") {
+		t.Fatalf("expected unclosed delimiter to be kept as text, got %q", text[len(text)-10:])
+	}
+}
+
+func TestSpanHTML(t *testing.T) {
+	output := ParseMarkdown("<b>**hi**</b> [x](https://e.com/?a=1&b=\"2\")").HTML()
+	expected := `&lt;b&gt;<span style="font-weight:bold">hi</span>&lt;/b&gt; <a href="https://e.com/?a=1&amp;b=&#34;2&#34;">x</a>`
+	if output != expected {
+		t.Fatalf("expected %q, got %q", expected, output)
+	}
+
+	output = ParseMarkdown("[click](javascript:location='//evil.example') [x](JavaScript://e.com)").HTML()
+	expected = `[click](javascript:location=&#39;//evil.example&#39;) [x](JavaScript://e.com)`
+	if output != expected {
+		t.Fatalf("expected links which are not http or https to be text, got %q", output)
+	}
+
+	output = ParseFormat("&4&nred").HTML()
+	expected = `<span style="color:#AA0000;text-decoration:underline">red</span>`
+	if output != expected {
+		t.Fatalf("expected %q, got %q", expected, output)
+	}
+}
+
+func TestSpanANSI(t *testing.T) {
+	output := ParseFormat("plain &a&lgreen").ANSI()
+	expected := "\x1b[0mplain \x1b[0;92;1mgreen\x1b[0m"
+	if output != expected {
+		t.Fatalf("expected %q, got %q", expected, output)
+	}
+}
+
+func TestSpanANSIControlCharacters(t *testing.T) {
+	output := ParseFormat("hi \x1b]0;pwned\x07 \x1b[2J\u009b2J\nnext").ANSI()
+	expected := "\x1b[0mhi ]0;pwned [2J2J\nnext\x1b[0m"
+	if output != expected {
+		t.Fatalf("expected control characters to be removed, got %q", output)
+	}
+
+	output = (&Span{Style: Style{Link: "https://e.com/\x1b\\\x07"}, Text: "x"}).ANSI()
+	expected = "\x1b[0;4m\x1b]8;;https://e.com/\\\x1b\\x\x1b]8;;\x1b\\\x1b[0m"
+	if output != expected {
+		t.Fatalf("expected control characters to be removed from links, got %q", output)
+	}
+}
+
+// Internal function to describe a tree for comparisons in tests.
+func describe(span *Span) string {
+	var builder strings.Builder
+	for _, child := range span.Children {
+		var tag string
+		switch {
+		case child.Style.Bold:
+			tag = "b"
+		case child.Style.Italic:
+			tag = "i"
+		case child.Style.Underline:
+			tag = "u"
+		case child.Style.Strikethrough:
+			tag = "s"
+		case child.Style.Spoiler:
+			tag = "spoiler"
+		case child.Style.Code:
+			tag = "code"
+		case child.Style.Link != "":
+			tag = "link " + child.Style.Link
+		}
+
+		content := child.Text + describe(child)
+		if tag != "" {
+			content = fmt.Sprintf("[%s:%s]", tag, content)
+		}
+		builder.WriteString(content)
+	}
+	return builder.String()
+}
+
+func ExampleParseFormat() {
+	span := ParseFormat("&c&lError: &cnot found")
+	fmt.Println(span.PlainText())
+	fmt.Println(span.HTML())
+	// Output:
+	// Error: not found
+	// <span style="color:#FF5555;font-weight:bold">Error: </span><span style="color:#FF5555">not found</span>
+}